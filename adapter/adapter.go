@@ -1,6 +1,12 @@
 package adapter
 
-import "github.com/sirupsen/logrus"
+import (
+	"context"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
+)
 
 // Adapter structure contains an adapter name. All new adapters
 // must inherit BaseAdapter and implement only Setup() and Close()
@@ -8,6 +14,9 @@ import "github.com/sirupsen/logrus"
 type BaseAdapter struct {
 	name   string
 	Logger *logrus.Entry
+
+	dependencies []string
+	resolved     map[string]AdapterInterface
 }
 
 // Interface implements basic adapter functions. All new adapters
@@ -20,6 +29,12 @@ type AdapterInterface interface {
 	SetLogger(logger *logrus.Entry)
 }
 
+// Optional interface for adapters which are able to check their
+// connections. Adapters without the interface are considered healthy.
+type HealthCheckInterface interface {
+	HealthCheck(ctx context.Context) error
+}
+
 // Function allocates BaseAdapter structure with the name.
 func NewBaseAdapter(name string) *BaseAdapter {
 	if name == "" {
@@ -44,3 +59,38 @@ func (a *BaseAdapter) GetName() string {
 func (a *BaseAdapter) SetLogger(logger *logrus.Entry) {
 	a.Logger = logger
 }
+
+// Function declares names of adapters which must be set up before
+// this one and closed after it.
+func (a *BaseAdapter) DependsOn(names ...string) {
+	for _, name := range names {
+		if !slices.Contains(a.dependencies, name) {
+			a.dependencies = append(a.dependencies, name)
+		}
+	}
+}
+
+// Function returns names of adapters the adapter depends on.
+func (a *BaseAdapter) GetDependencies() []string {
+	return slices.Clone(a.dependencies)
+}
+
+// Function receives a dependency which is already set up. It is
+// called by the framework before Setup().
+func (a *BaseAdapter) SetDependency(adap AdapterInterface) {
+	if a.resolved == nil {
+		a.resolved = make(map[string]AdapterInterface)
+	}
+
+	a.resolved[adap.GetName()] = adap
+}
+
+// Function returns a dependency that can be converted to
+// a particular adapter structure. Use it in Setup().
+func (a *BaseAdapter) GetDependency(name string) (AdapterInterface, error) {
+	if adap, ok := a.resolved[name]; ok {
+		return adap, nil
+	}
+
+	return nil, fmt.Errorf("dependency %s of the adapter %s is not found", name, a.name)
+}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
@@ -18,10 +19,10 @@ import (
 
 type OidcConfig struct {
 	OfflineMode     bool     `json:"OfflineMode,omitempty" config:"OfflineMode"`
-	ProviderUrl     string   `json:"ProviderUrl" config:"ProviderUrl,required"`
+	ProviderUrl     string   `json:"ProviderUrl" config:"ProviderUrl,required,url"`
 	ClientId        string   `json:"ClientId" config:"ClientId,required"`
 	ClientSecret    string   `json:"ClientSecret" config:"ClientSecret"`
-	RedirectURL     string   `json:"RedirectURL,omitempty" config:"RedirectURL"`
+	RedirectURL     string   `json:"RedirectURL,omitempty" config:"RedirectURL,url"`
 	Scopes          []string `json:"Scopes,omitempty" config:"Scopes"`
 	PublicKeys      []string `json:"PublicKeys,omitempty" config:"PublicKeys"`
 	SkipIssuerCheck bool     `json:"SkipIssuerCheck,omitempty" config:"SkipIssuerCheck"`
@@ -155,7 +156,11 @@ func (a *OidcAdapter) Introspect(token string) (err error) {
 }
 
 func (a *OidcAdapter) VerifyToken(token string) (err error) {
-	tokenInfo, err := a.TokenInfo(token)
+	return a.VerifyTokenContext(context.Background(), token)
+}
+
+func (a *OidcAdapter) VerifyTokenContext(ctx context.Context, token string) (err error) {
+	tokenInfo, err := a.TokenInfoContext(ctx, token)
 
 	if err != nil {
 		return
@@ -177,6 +182,10 @@ func (a *OidcAdapter) VerifyToken(token string) (err error) {
 }
 
 func (a *OidcAdapter) TokenInfo(token string) (tokenInfo *oidc.IDToken, err error) {
+	return a.TokenInfoContext(context.Background(), token)
+}
+
+func (a *OidcAdapter) TokenInfoContext(ctx context.Context, token string) (tokenInfo *oidc.IDToken, err error) {
 	verifier := a.GetVerifier()
 
 	if verifier == nil {
@@ -186,7 +195,7 @@ func (a *OidcAdapter) TokenInfo(token string) (tokenInfo *oidc.IDToken, err erro
 		return
 	}
 
-	tokenInfo, err = verifier.Verify(context.TODO(), token)
+	tokenInfo, err = verifier.Verify(ctx, token)
 
 	if err != nil {
 		a.Logger.Errorf("cannot get token info - %s", err)
@@ -194,3 +203,35 @@ func (a *OidcAdapter) TokenInfo(token string) (tokenInfo *oidc.IDToken, err erro
 
 	return
 }
+
+// Function requests the provider discovery document. The offline
+// mode is always healthy.
+func (a *OidcAdapter) HealthCheck(ctx context.Context) error {
+	if a.config.OfflineMode {
+		return nil
+	}
+
+	if a.provider == nil {
+		return errors.New("provider is not available")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(a.config.ProviderUrl, "/")+"/.well-known/openid-configuration", nil)
+
+	if err != nil {
+		return err
+	}
+
+	res, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return errors.New(res.Status)
+	}
+
+	return nil
+}
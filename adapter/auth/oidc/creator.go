@@ -0,0 +1,25 @@
+package oidc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/radianteam/framework/adapter"
+	"github.com/radianteam/framework/adapter/util/config"
+)
+
+// Function creates the OIDC adapter from a configuration section.
+func OidcAdapterCreate(name string, configAdapter *config.ConfigAdapter) (adapter.AdapterInterface, error) {
+	if configAdapter == nil {
+		return nil, errors.New("configuration adapter is not provided")
+	}
+
+	adapterConfig := &OidcConfig{}
+	err := configAdapter.Unmarshal(adapterConfig, false)
+
+	if err != nil {
+		return nil, fmt.Errorf("configuration loading error for the adapter with name %s: %v", name, err)
+	}
+
+	return NewOidcAdapter(name, adapterConfig), nil
+}
@@ -0,0 +1,122 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/exp/slices"
+)
+
+// Optional interface for adapters which depend on other adapters.
+// It is implemented by BaseAdapter.
+type DependentInterface interface {
+	GetDependencies() []string
+	SetDependency(adap AdapterInterface)
+}
+
+// Function returns dependency names of an adapter.
+func GetDependencies(adap AdapterInterface) []string {
+	if dependent, ok := adap.(DependentInterface); ok {
+		return dependent.GetDependencies()
+	}
+
+	return nil
+}
+
+// Function groups names into levels. Every name depends only on
+// names of previous levels, so names of one level are independent.
+// Dependencies which are not in the list are ignored. Names keep
+// their order inside a level. A dependency cycle is an error.
+func SortLevels(names []string, dependencies func(name string) []string) ([][]string, error) {
+	levels := [][]string{}
+	placed := map[string]bool{}
+	rest := slices.Clone(names)
+
+	for len(rest) > 0 {
+		level := []string{}
+		next := []string{}
+
+		for _, name := range rest {
+			ready := true
+
+			for _, dep := range dependencies(name) {
+				if slices.Contains(names, dep) && !placed[dep] {
+					ready = false
+					break
+				}
+			}
+
+			if ready {
+				level = append(level, name)
+			} else {
+				next = append(next, name)
+			}
+		}
+
+		if len(level) == 0 {
+			return nil, fmt.Errorf("dependency cycle between adapters: %s", strings.Join(next, ", "))
+		}
+
+		for _, name := range level {
+			placed[name] = true
+		}
+
+		levels = append(levels, level)
+		rest = next
+	}
+
+	return levels, nil
+}
+
+// Function calls setup for all names level by level. Names of
+// one level are set up in parallel. The next level is not started
+// if any setup fails. It returns names set up successfully in the
+// order they should be closed in reverse and the combined error.
+func SetupLevels(levels [][]string, setup func(name string) error) ([]string, error) {
+	done := []string{}
+
+	for _, level := range levels {
+		errs := make([]error, len(level))
+		wg := sync.WaitGroup{}
+
+		for i, name := range level {
+			wg.Add(1)
+
+			go func(i int, name string) {
+				defer wg.Done()
+
+				errs[i] = setup(name)
+			}(i, name)
+		}
+
+		wg.Wait()
+
+		for i, name := range level {
+			if errs[i] == nil {
+				done = append(done, name)
+			}
+		}
+
+		if err := errors.Join(errs...); err != nil {
+			return done, err
+		}
+	}
+
+	return done, nil
+}
+
+// Function calls close for names in the reverse order. All names
+// are closed even if some of them fail. Errors are combined.
+func CloseReverse(names []string, close func(name string) error) error {
+	errs := []error{}
+
+	for i := len(names) - 1; i >= 0; i-- {
+		if err := close(names[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,143 @@
+package adapter
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/exp/slices"
+)
+
+func dependencyMap(graph map[string][]string) func(name string) []string {
+	return func(name string) []string {
+		return graph[name]
+	}
+}
+
+func TestSortLevels(t *testing.T) {
+	tests := []struct {
+		name     string
+		names    []string
+		graph    map[string][]string
+		expected [][]string
+	}{
+		{"no names", nil, nil, [][]string{}},
+		{"independent", []string{"a", "b", "c"}, nil, [][]string{{"a", "b", "c"}}},
+		{"chain", []string{"c", "b", "a"}, map[string][]string{"c": {"b"}, "b": {"a"}}, [][]string{{"a"}, {"b"}, {"c"}}},
+		{"diamond", []string{"app", "cache", "db", "config"}, map[string][]string{"app": {"cache", "db"}, "cache": {"config"}, "db": {"config"}}, [][]string{{"config"}, {"cache", "db"}, {"app"}}},
+		{"order inside a level", []string{"d", "b", "a", "c"}, map[string][]string{"d": {"a"}, "b": {"a"}}, [][]string{{"a", "c"}, {"d", "b"}}},
+		{"unknown dependency", []string{"a", "b"}, map[string][]string{"a": {"missing"}, "b": {"a"}}, [][]string{{"a"}, {"b"}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			levels, err := SortLevels(test.names, dependencyMap(test.graph))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(levels, test.expected) {
+				t.Errorf("levels %v, expected %v", levels, test.expected)
+			}
+		})
+	}
+}
+
+func TestSortLevelsCycle(t *testing.T) {
+	tests := []struct {
+		name  string
+		names []string
+		graph map[string][]string
+		cycle string
+	}{
+		{"self", []string{"a"}, map[string][]string{"a": {"a"}}, "a"},
+		{"pair", []string{"a", "b"}, map[string][]string{"a": {"b"}, "b": {"a"}}, "a, b"},
+		{"after a level", []string{"a", "b", "c", "d"}, map[string][]string{"b": {"a", "d"}, "c": {"b"}, "d": {"c"}}, "b, c, d"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := SortLevels(test.names, dependencyMap(test.graph))
+
+			if err == nil || !strings.HasSuffix(err.Error(), ": "+test.cycle) {
+				t.Errorf("expected the cycle %s, got %v", test.cycle, err)
+			}
+		})
+	}
+}
+
+func TestSetupLevelsAndCloseReverse(t *testing.T) {
+	levels := [][]string{{"config"}, {"cache", "db", "queue"}, {"app"}}
+	failure := errors.New("setup failed")
+
+	mutex := sync.Mutex{}
+	setUp := []string{}
+
+	done, err := SetupLevels(levels, func(name string) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		setUp = append(setUp, name)
+
+		if name == "db" {
+			return failure
+		}
+
+		return nil
+	})
+
+	if !errors.Is(err, failure) {
+		t.Fatalf("expected the setup error, got %v", err)
+	}
+
+	// the failed level is finished, the next level is not started
+	if len(setUp) != 4 || setUp[0] != "config" || slices.Contains(setUp, "app") {
+		t.Errorf("set up %v", setUp)
+	}
+
+	expected := []string{"config", "cache", "queue"}
+
+	if !reflect.DeepEqual(done, expected) {
+		t.Errorf("done %v, expected %v", done, expected)
+	}
+
+	closed := []string{}
+
+	err = CloseReverse(done, func(name string) error {
+		closed = append(closed, name)
+
+		if name == "queue" {
+			return failure
+		}
+
+		return nil
+	})
+
+	if !errors.Is(err, failure) {
+		t.Errorf("expected the close error, got %v", err)
+	}
+
+	if !reflect.DeepEqual(closed, []string{"queue", "cache", "config"}) {
+		t.Errorf("closed %v", closed)
+	}
+}
+
+func TestSetupLevelsParallel(t *testing.T) {
+	started := sync.WaitGroup{}
+	started.Add(2)
+
+	// both names of the level wait for each other
+	done, err := SetupLevels([][]string{{"a", "b"}}, func(name string) error {
+		started.Done()
+		started.Wait()
+
+		return nil
+	})
+
+	if err != nil || len(done) != 2 {
+		t.Errorf("done %v, error %v", done, err)
+	}
+}
@@ -1,8 +1,10 @@
 package rabbitmq
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/radianteam/framework/adapter"
@@ -16,10 +18,13 @@ const (
 
 type RabbitMqConfig struct {
 	Host     string `json:"Host,omitempty" config:"Host,required"`
-	Port     uint16 `json:"Port,omitempty" config:"Port,required"`
+	Port     uint16 `json:"Port,omitempty" config:"Port,required,min=1"`
 	Username string `json:"Username,omitempty" config:"Username"`
 	Password string `json:"Password,omitempty" config:"Password"`
 	Exchange string `json:"Exchange,omitempty" config:"Exchange"`
+
+	// declared on setup and after reconnecting
+	Topology RabbitMqTopology `json:"Topology,omitempty" config:"Topology"`
 }
 
 type RabbitMqAdapter struct {
@@ -29,6 +34,7 @@ type RabbitMqAdapter struct {
 
 	connection        *amqp.Connection
 	channel           *amqp.Channel
+	publishMutex      sync.Mutex
 	notifyPublishChan chan amqp.Confirmation
 	notifyCloseChan   chan *amqp.Error
 }
@@ -44,7 +50,7 @@ func (a *RabbitMqAdapter) setupChannel() (err error) {
 		return
 	}
 
-	a.channel.Confirm(false)
+	err = a.channel.Confirm(false)
 	if err != nil {
 		a.Logger.Error(err)
 		return
@@ -60,6 +66,28 @@ func (a *RabbitMqAdapter) setupChannel() (err error) {
 }
 
 func (a *RabbitMqAdapter) Setup() (err error) {
+	a.connection, err = amqp.Dial(a.url())
+	if err != nil {
+		a.Logger.Error(err)
+		return
+	}
+
+	// the connection is closed on errors, Setup() opens a new one
+	if err = a.setupChannel(); err != nil {
+		a.Close()
+
+		return
+	}
+
+	if err = a.config.Topology.declare(a.channel); err != nil {
+		a.Logger.Error(err)
+		a.Close()
+	}
+
+	return
+}
+
+func (a *RabbitMqAdapter) url() string {
 	connStr := ""
 	if a.config.Username != "" {
 		connStr += a.config.Username
@@ -70,24 +98,46 @@ func (a *RabbitMqAdapter) Setup() (err error) {
 
 		connStr += "@"
 	}
-	connStr = fmt.Sprintf("amqp://%s%s:%d/", connStr, a.config.Host, a.config.Port)
 
-	a.connection, err = amqp.Dial(connStr)
-	if err != nil {
-		a.Logger.Error(err)
-		return
+	return fmt.Sprintf("amqp://%s%s:%d/", connStr, a.config.Host, a.config.Port)
+}
+
+// Function closes the channel and the connection. It can be called
+// after the connection has been lost or has not been established.
+func (a *RabbitMqAdapter) Close() (err error) {
+	if a.connection == nil {
+		return nil
 	}
 
-	return a.setupChannel()
+	if a.channel != nil {
+		if err = a.channel.Close(); err != nil && !errors.Is(err, amqp.ErrClosed) {
+			a.Logger.Error(err)
+		}
+	}
+
+	if err = a.connection.Close(); errors.Is(err, amqp.ErrClosed) {
+		return nil
+	}
+
+	return err
 }
 
-func (a *RabbitMqAdapter) Close() (err error) {
-	if err = a.channel.Close(); err != nil {
-		a.Logger.Error(err)
-		return
+// Function opens a new channel on the connection. Consumers use
+// their own channels so an error of a queue does not close the
+// publishing channel.
+func (a *RabbitMqAdapter) OpenChannel() (*amqp.Channel, error) {
+	if a.connection == nil || a.connection.IsClosed() {
+		return nil, errors.New("connection is closed")
 	}
 
-	return a.connection.Close()
+	return a.connection.Channel()
+}
+
+// Function returns a channel receiving the error when the current
+// connection is closed by the broker or the network. The channel
+// is closed without an error if the connection is closed by Close().
+func (a *RabbitMqAdapter) NotifyConnectionClose() <-chan *amqp.Error {
+	return a.connection.NotifyClose(make(chan *amqp.Error, 1))
 }
 
 func (a *RabbitMqAdapter) checkConnection() (err error) {
@@ -98,7 +148,7 @@ func (a *RabbitMqAdapter) checkConnection() (err error) {
 	select {
 	case x, ok := <-a.notifyCloseChan:
 		if ok {
-			a.Logger.Debug("AMQP channel closed with error: %v", x)
+			a.Logger.Debugf("AMQP channel closed with error: %v", x)
 		} else {
 			a.Logger.Debug("AMQP channel closed!")
 		}
@@ -112,39 +162,76 @@ func (a *RabbitMqAdapter) checkConnection() (err error) {
 }
 
 func (a *RabbitMqAdapter) DeclareExchange(name string, kind string, durable bool) (err error) {
+	return a.DeclareExchangeConfig(&RabbitMqExchangeConfig{Name: name, Type: kind, Durable: durable})
+}
+
+// Function declares an exchange with all flags and arguments.
+func (a *RabbitMqAdapter) DeclareExchangeConfig(config *RabbitMqExchangeConfig) (err error) {
 	if err = a.checkConnection(); err != nil {
 		return
 	}
 
-	return a.channel.ExchangeDeclare(name, kind, durable, false, false, false, nil)
+	return declareExchange(a.channel, config)
 }
 
 func (a *RabbitMqAdapter) DeclareQueue(name string, durable bool) (err error) {
+	return a.DeclareQueueConfig(&RabbitMqQueueConfig{Name: name, Durable: durable})
+}
+
+// Function declares a queue with all flags and arguments.
+func (a *RabbitMqAdapter) DeclareQueueConfig(config *RabbitMqQueueConfig) (err error) {
 	if err = a.checkConnection(); err != nil {
 		return
 	}
 
-	_, err = a.channel.QueueDeclare(name, durable, false, false, false, nil)
-
-	return err
+	return declareQueue(a.channel, config)
 }
 
 func (a *RabbitMqAdapter) BindQueue(exchange string, routingKey string, queue string) (err error) {
+	return a.BindQueueConfig(&RabbitMqBindingConfig{Exchange: exchange, Queue: queue, RoutingKey: routingKey})
+}
+
+// Function binds a queue with arguments of headers exchanges.
+func (a *RabbitMqAdapter) BindQueueConfig(config *RabbitMqBindingConfig) (err error) {
 	if err = a.checkConnection(); err != nil {
 		return
 	}
 
-	err = a.channel.QueueBind(queue, routingKey, exchange, false, nil)
+	return bindQueue(a.channel, config)
+}
 
-	return err
+// Function declares the topology of the configuration again, e.g.
+// after objects have been deleted.
+func (a *RabbitMqAdapter) DeclareTopology() (err error) {
+	if err = a.checkConnection(); err != nil {
+		return
+	}
+
+	return a.config.Topology.declare(a.channel)
 }
 
 func (a *RabbitMqAdapter) PublishExchange(exchange string, key string, message []byte) (err error) {
+	return a.PublishExchangeContext(context.Background(), exchange, key, message)
+}
+
+// Function publishes a message and waits for the confirmation
+// until the timeout is reached or the context is cancelled.
+func (a *RabbitMqAdapter) PublishExchangeContext(ctx context.Context, exchange string, key string, message []byte) (err error) {
+	return a.PublishRawContext(ctx, exchange, key, amqp.Publishing{Body: message})
+}
+
+// Function publishes a message with properties and headers and
+// waits for the confirmation. Messages are published one by one so
+// confirmations are not mixed up by concurrent publishers.
+func (a *RabbitMqAdapter) PublishRawContext(ctx context.Context, exchange string, key string, message amqp.Publishing) (err error) {
+	a.publishMutex.Lock()
+	defer a.publishMutex.Unlock()
+
 	if err = a.checkConnection(); err != nil {
 		return
 	}
 
-	err = a.channel.Publish(exchange, key, false, false, amqp.Publishing{Body: message}) // TODO: connection can be ok but channel is closed
+	err = a.channel.Publish(exchange, key, false, false, message) // TODO: connection can be ok but channel is closed
 
 	if err != nil {
 		return err
@@ -156,6 +243,8 @@ func (a *RabbitMqAdapter) PublishExchange(exchange string, key string, message [
 	case confirmation = <-a.notifyPublishChan:
 	case <-time.After(time.Millisecond * RabbitMqPublishTimeoutMs):
 		return errors.New("publishing error: timeout")
+	case <-ctx.Done():
+		return fmt.Errorf("publishing error: %w", ctx.Err())
 	}
 
 	if confirmation.Ack {
@@ -168,3 +257,16 @@ func (a *RabbitMqAdapter) PublishExchange(exchange string, key string, message [
 func (a *RabbitMqAdapter) Publish(key string, message []byte) (err error) {
 	return a.PublishExchange(a.config.Exchange, key, message)
 }
+
+func (a *RabbitMqAdapter) PublishContext(ctx context.Context, key string, message []byte) (err error) {
+	return a.PublishExchangeContext(ctx, a.config.Exchange, key, message)
+}
+
+// Function checks the state of the connection.
+func (a *RabbitMqAdapter) HealthCheck(_ context.Context) error {
+	if a.connection == nil || a.connection.IsClosed() {
+		return errors.New("connection is closed")
+	}
+
+	return nil
+}
@@ -0,0 +1,25 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/radianteam/framework/adapter"
+	"github.com/radianteam/framework/adapter/util/config"
+)
+
+// Function creates the RabbitMQ adapter from a configuration section.
+func RabbitMqAdapterCreate(name string, configAdapter *config.ConfigAdapter) (adapter.AdapterInterface, error) {
+	if configAdapter == nil {
+		return nil, errors.New("configuration adapter is not provided")
+	}
+
+	adapterConfig := &RabbitMqConfig{}
+	err := configAdapter.Unmarshal(adapterConfig, false)
+
+	if err != nil {
+		return nil, fmt.Errorf("configuration loading error for the adapter with name %s: %v", name, err)
+	}
+
+	return NewRabbitMqAdapter(name, adapterConfig), nil
+}
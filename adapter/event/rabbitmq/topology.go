@@ -0,0 +1,160 @@
+package rabbitmq
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/streadway/amqp"
+)
+
+// Exchanges, queues and bindings declared by the adapter on every
+// connection. Declarations are idempotent: existing objects with the
+// same settings are kept, objects with other settings are an error
+// of the broker.
+type RabbitMqTopology struct {
+	Exchanges []RabbitMqExchangeConfig `json:"Exchanges,omitempty" config:"Exchanges"`
+	Queues    []RabbitMqQueueConfig    `json:"Queues,omitempty" config:"Queues"`
+	Bindings  []RabbitMqBindingConfig  `json:"Bindings,omitempty" config:"Bindings"`
+}
+
+type RabbitMqExchangeConfig struct {
+	Name       string         `json:"Name,omitempty" config:"Name,required,nonempty"`
+	Type       string         `json:"Type,omitempty" config:"Type,default=direct"`
+	Durable    bool           `json:"Durable,omitempty" config:"Durable,default=true"`
+	AutoDelete bool           `json:"AutoDelete,omitempty" config:"AutoDelete"`
+	Internal   bool           `json:"Internal,omitempty" config:"Internal"`
+	Arguments  map[string]any `json:"Arguments,omitempty" config:"Arguments"`
+}
+
+type RabbitMqQueueConfig struct {
+	Name       string `json:"Name,omitempty" config:"Name,required,nonempty"`
+	Durable    bool   `json:"Durable,omitempty" config:"Durable,default=true"`
+	AutoDelete bool   `json:"AutoDelete,omitempty" config:"AutoDelete"`
+	Exclusive  bool   `json:"Exclusive,omitempty" config:"Exclusive"`
+
+	// the "x-queue-type" argument
+	Type string `json:"Type,omitempty" config:"Type,oneof=classic|quorum|stream"`
+
+	// e.g. "x-message-ttl", "x-dead-letter-exchange" or "x-max-length"
+	Arguments map[string]any `json:"Arguments,omitempty" config:"Arguments"`
+}
+
+// Binding of a queue to an exchange. Headers exchanges match the
+// arguments.
+type RabbitMqBindingConfig struct {
+	Exchange   string         `json:"Exchange,omitempty" config:"Exchange,required,nonempty"`
+	Queue      string         `json:"Queue,omitempty" config:"Queue,required,nonempty"`
+	RoutingKey string         `json:"RoutingKey,omitempty" config:"RoutingKey"`
+	Arguments  map[string]any `json:"Arguments,omitempty" config:"Arguments"`
+}
+
+// Function declares all exchanges, then queues and then bindings of
+// the topology on the channel.
+func (t *RabbitMqTopology) declare(channel *amqp.Channel) (err error) {
+	for idx := range t.Exchanges {
+		if err = declareExchange(channel, &t.Exchanges[idx]); err != nil {
+			return
+		}
+	}
+
+	for idx := range t.Queues {
+		if err = declareQueue(channel, &t.Queues[idx]); err != nil {
+			return
+		}
+	}
+
+	for idx := range t.Bindings {
+		if err = bindQueue(channel, &t.Bindings[idx]); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func declareExchange(channel *amqp.Channel, config *RabbitMqExchangeConfig) error {
+	kind := config.Type
+
+	if kind == "" {
+		kind = amqp.ExchangeDirect
+	}
+
+	err := channel.ExchangeDeclare(config.Name, kind, config.Durable, config.AutoDelete, config.Internal, false, arguments(config.Arguments))
+
+	if err != nil {
+		return fmt.Errorf("exchange %s cannot be declared: %w", config.Name, err)
+	}
+
+	return nil
+}
+
+func declareQueue(channel *amqp.Channel, config *RabbitMqQueueConfig) error {
+	args := arguments(config.Arguments)
+
+	if config.Type != "" {
+		if args == nil {
+			args = amqp.Table{}
+		}
+
+		args["x-queue-type"] = config.Type
+	}
+
+	_, err := channel.QueueDeclare(config.Name, config.Durable, config.AutoDelete, config.Exclusive, false, args)
+
+	if err != nil {
+		return fmt.Errorf("queue %s cannot be declared: %w", config.Name, err)
+	}
+
+	return nil
+}
+
+func bindQueue(channel *amqp.Channel, config *RabbitMqBindingConfig) error {
+	err := channel.QueueBind(config.Queue, config.RoutingKey, config.Exchange, false, arguments(config.Arguments))
+
+	if err != nil {
+		return fmt.Errorf("queue %s cannot be bound to %s: %w", config.Queue, config.Exchange, err)
+	}
+
+	return nil
+}
+
+// Function converts configuration values to the AMQP table.
+// Integers of configuration files are already int64, floats without
+// a fraction set by code become integers too, the broker does not
+// accept floats for TTLs and limits.
+func arguments(values map[string]any) amqp.Table {
+	if values == nil {
+		return nil
+	}
+
+	table := amqp.Table{}
+
+	for key, value := range values {
+		table[key] = argument(value)
+	}
+
+	return table
+}
+
+func argument(value any) any {
+	switch v := value.(type) {
+	case float64:
+		if v == math.Trunc(v) && math.Abs(v) < math.MaxInt64 {
+			return int64(v)
+		}
+	case int:
+		return int64(v)
+	case map[string]any:
+		return arguments(v)
+	case []any:
+		list := make([]any, len(v))
+
+		for idx, item := range v {
+			list[idx] = argument(item)
+		}
+
+		return list
+	}
+
+	return value
+}
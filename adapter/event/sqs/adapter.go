@@ -1,6 +1,7 @@
 package sqs
 
 import (
+	"context"
 	"errors"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -11,14 +12,14 @@ import (
 )
 
 type AwsSqsConfig struct {
-	Endpoint            string `json:"Endpoint,omitempty" config:"Endpoint"`
+	Endpoint            string `json:"Endpoint,omitempty" config:"Endpoint,url"`
 	Region              string `json:"Region,omitempty" config:"Region,required"`
 	AccessKeyID         string `json:"AccessKeyID,omitempty" config:"AccessKeyID"`
 	SecretAccessKey     string `json:"SecretAccessKey,omitempty" config:"SecretAccessKey"`
 	SessionToken        string `json:"SessionToken,omitempty" config:"SessionToken"`
-	MaxNumberOfMessages int64  `json:"MaxNumberOfMessages" config:"MaxNumberOfMessages"`
-	WaitTimeSeconds     int64  `json:"WaitTimeSeconds" config:"WaitTimeSeconds"`
-	VisibilityTimeout   int64  `json:"VisibilityTimeout" config:"VisibilityTimeout"`
+	MaxNumberOfMessages int64  `json:"MaxNumberOfMessages" config:"MaxNumberOfMessages,min=0,max=10"`
+	WaitTimeSeconds     int64  `json:"WaitTimeSeconds" config:"WaitTimeSeconds,max=20"`
+	VisibilityTimeout   int64  `json:"VisibilityTimeout" config:"VisibilityTimeout,max=43200"`
 	SharedCredentials   bool   `json:"SharedCredentials,omitempty" config:"SharedCredentials"`
 
 	Queue string `json:"Queue,omitempty" config:"Queue"`
@@ -75,8 +76,12 @@ func (a *AwsSqsAdapter) ListQueues() (*[]*string, error) {
 }
 
 func (a *AwsSqsAdapter) GetQueueUrl(qName string) (string, error) {
+	return a.GetQueueUrlContext(context.Background(), qName)
+}
+
+func (a *AwsSqsAdapter) GetQueueUrlContext(ctx context.Context, qName string) (string, error) {
 	getQueueUrlInput := &sqs.GetQueueUrlInput{QueueName: aws.String(qName)}
-	result, err := a.client.GetQueueUrl(getQueueUrlInput)
+	result, err := a.client.GetQueueUrlWithContext(ctx, getQueueUrlInput)
 	if err != nil {
 		return "", err
 	}
@@ -97,34 +102,46 @@ func (a *AwsSqsAdapter) DeleteQueue(qName string) (err error) {
 }
 
 func (a *AwsSqsAdapter) DeleteMessage(qName string, receiptHandle string) (err error) {
-	queueUrl, err := a.GetQueueUrl(qName)
+	return a.DeleteMessageContext(context.Background(), qName, receiptHandle)
+}
+
+func (a *AwsSqsAdapter) DeleteMessageContext(ctx context.Context, qName string, receiptHandle string) (err error) {
+	queueUrl, err := a.GetQueueUrlContext(ctx, qName)
 	if err != nil {
 		return
 	}
 
 	deleteMessageInput := &sqs.DeleteMessageInput{QueueUrl: aws.String(queueUrl), ReceiptHandle: aws.String(receiptHandle)}
-	_, err = a.client.DeleteMessage(deleteMessageInput)
+	_, err = a.client.DeleteMessageWithContext(ctx, deleteMessageInput)
 
 	return
 }
 
 func (a *AwsSqsAdapter) Publish(message string) (err error) {
+	return a.PublishContext(context.Background(), message)
+}
+
+func (a *AwsSqsAdapter) PublishContext(ctx context.Context, message string) (err error) {
 	if a.config.Queue == "" {
 		return errors.New("queue name is empty")
 	}
 
-	return a.PublishQueue(a.config.Queue, message)
+	return a.PublishQueueContext(ctx, a.config.Queue, message)
 }
 
 func (a *AwsSqsAdapter) PublishQueue(qName string, message string) (err error) {
-	queueUrl, err := a.GetQueueUrl(qName)
+	return a.PublishQueueContext(context.Background(), qName, message)
+}
+
+func (a *AwsSqsAdapter) PublishQueueContext(ctx context.Context, qName string, message string) (err error) {
+	queueUrl, err := a.GetQueueUrlContext(ctx, qName)
 
 	if err != nil {
 		return
 	}
 
 	sendMessageInput := &sqs.SendMessageInput{QueueUrl: aws.String(queueUrl), MessageBody: aws.String(message)}
-	_, err = a.client.SendMessage(sendMessageInput)
+	_, err = a.client.SendMessageWithContext(ctx, sendMessageInput)
 
 	return
 }
@@ -138,7 +155,18 @@ func (a *AwsSqsAdapter) PublishQueueBatchRaw(input *sqs.SendMessageBatchInput) (
 }
 
 func (a *AwsSqsAdapter) Consume(queueUrl string) ([]*sqs.Message, error) {
-	receiveMessageInput := &sqs.ReceiveMessageInput{QueueUrl: aws.String(queueUrl)}
+	return a.ConsumeContext(context.Background(), queueUrl)
+}
+
+// Function receives messages. Long polling is interrupted when
+// the context is cancelled. The message group id of FIFO queues is
+// received as a message attribute.
+func (a *AwsSqsAdapter) ConsumeContext(ctx context.Context, queueUrl string) ([]*sqs.Message, error) {
+	receiveMessageInput := &sqs.ReceiveMessageInput{
+		QueueUrl:       aws.String(queueUrl),
+		AttributeNames: []*string{aws.String(sqs.MessageSystemAttributeNameMessageGroupId)},
+	}
+	// zero values are not sent, SQS uses its defaults
 	if a.config.MaxNumberOfMessages != 0 {
 		receiveMessageInput.MaxNumberOfMessages = aws.Int64(a.config.MaxNumberOfMessages)
 	}
@@ -148,10 +176,28 @@ func (a *AwsSqsAdapter) Consume(queueUrl string) ([]*sqs.Message, error) {
 	if a.config.VisibilityTimeout != 0 {
 		receiveMessageInput.VisibilityTimeout = aws.Int64(a.config.VisibilityTimeout)
 	}
-	res, err := a.client.ReceiveMessage(receiveMessageInput)
+	res, err := a.client.ReceiveMessageWithContext(ctx, receiveMessageInput)
 	if err != nil {
 		return nil, err
 	}
 
 	return res.Messages, nil
 }
+
+// Function checks that the service is reachable. If the default
+// queue is configured its url is requested.
+func (a *AwsSqsAdapter) HealthCheck(ctx context.Context) (err error) {
+	if a.client == nil {
+		return errors.New("client is not configured")
+	}
+
+	if a.config.Queue != "" {
+		_, err = a.client.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(a.config.Queue)})
+
+		return
+	}
+
+	_, err = a.client.ListQueuesWithContext(ctx, &sqs.ListQueuesInput{MaxResults: aws.Int64(1)})
+
+	return
+}
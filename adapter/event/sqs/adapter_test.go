@@ -0,0 +1,51 @@
+package sqs
+
+import (
+	"testing"
+
+	"github.com/radianteam/framework/adapter/util/config"
+)
+
+func TestAwsSqsConfigMaxNumberOfMessages(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		expected int64
+		err      bool
+	}{
+		{"unset", `{"Region": "eu-west-1"}`, 0, false},
+		{"explicit zero", `{"Region": "eu-west-1", "MaxNumberOfMessages": 0}`, 0, false},
+		{"set", `{"Region": "eu-west-1", "MaxNumberOfMessages": 10}`, 10, false},
+		{"negative", `{"Region": "eu-west-1", "MaxNumberOfMessages": -1}`, 0, true},
+		{"above the limit", `{"Region": "eu-west-1", "MaxNumberOfMessages": 11}`, 0, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			configAdapter := config.NewConfigAdapter("test")
+
+			if err := configAdapter.LoadFromJson([]byte(test.document)); err != nil {
+				t.Fatal(err)
+			}
+
+			adapterConfig := &AwsSqsConfig{}
+			err := configAdapter.Unmarshal(adapterConfig, false)
+
+			if test.err {
+				if err == nil {
+					t.Errorf("error is expected, decoded %d", adapterConfig.MaxNumberOfMessages)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if adapterConfig.MaxNumberOfMessages != test.expected {
+				t.Errorf("decoded %d, expected %d", adapterConfig.MaxNumberOfMessages, test.expected)
+			}
+		})
+	}
+}
@@ -3,6 +3,7 @@ package arangodb
 import (
 	"context"
 	"crypto/tls"
+	"errors"
 
 	"github.com/radianteam/framework/adapter"
 
@@ -11,7 +12,7 @@ import (
 )
 
 type ArangoDbConfig struct {
-	Servers            []string `json:"Servers,omitempty" config:"Servers,required"`
+	Servers            []string `json:"Servers,omitempty" config:"Servers,required,nonempty,url"`
 	Username           string   `json:"Username,omitempty" config:"Username,required"`
 	Password           string   `json:"Password,omitempty" config:"Password"`
 	Database           string   `json:"Database,omitempty" config:"Database,required"`
@@ -23,6 +24,7 @@ type ArangoDbAdapter struct {
 
 	config *ArangoDbConfig
 
+	client   driver.Client
 	database driver.Database
 }
 
@@ -49,6 +51,8 @@ func (a *ArangoDbAdapter) Setup() (err error) {
 		return
 	}
 
+	a.client = client
+
 	if ok, _ := client.DatabaseExists(context.TODO(), a.config.Database); !ok {
 		a.database, err = client.CreateDatabase(context.TODO(), a.config.Database, nil)
 		if err != nil {
@@ -71,3 +75,14 @@ func (a *ArangoDbAdapter) Close() error {
 func (a *ArangoDbAdapter) Get() driver.Database {
 	return a.database
 }
+
+// Function requests the server version.
+func (a *ArangoDbAdapter) HealthCheck(ctx context.Context) (err error) {
+	if a.client == nil {
+		return errors.New("client is not connected")
+	}
+
+	_, err = a.client.Version(ctx)
+
+	return
+}
@@ -0,0 +1,25 @@
+package arangodb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/radianteam/framework/adapter"
+	"github.com/radianteam/framework/adapter/util/config"
+)
+
+// Function creates the ArangoDB adapter from a configuration section.
+func ArangoDbAdapterCreate(name string, configAdapter *config.ConfigAdapter) (adapter.AdapterInterface, error) {
+	if configAdapter == nil {
+		return nil, errors.New("configuration adapter is not provided")
+	}
+
+	adapterConfig := &ArangoDbConfig{}
+	err := configAdapter.Unmarshal(adapterConfig, false)
+
+	if err != nil {
+		return nil, fmt.Errorf("configuration loading error for the adapter with name %s: %v", name, err)
+	}
+
+	return NewArangoDbAdapter(name, adapterConfig), nil
+}
@@ -6,6 +6,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"errors"
 	"os"
 	"strings"
 
@@ -16,7 +17,7 @@ import (
 )
 
 type MongoDbConfig struct {
-	Hosts            []string `json:"Hosts,omitempty" config:"Hosts,required"`
+	Hosts            []string `json:"Hosts,omitempty" config:"Hosts,required,nonempty"`
 	Username         string   `json:"Username,omitempty" config:"Username"`
 	Password         string   `json:"Password,omitempty" config:"Password"`
 	ReplicaSet       string   `json:"ReplicaSet,omitempty" config:"ReplicaSet"`
@@ -92,3 +93,12 @@ func (a *MongoDbAdapter) GetCollection(dbName string, collName string) *mongo.Co
 func (a *MongoDbAdapter) CreateSession() (mongo.Session, error) {
 	return a.client.StartSession()
 }
+
+// Function pings the primary node of the cluster.
+func (a *MongoDbAdapter) HealthCheck(ctx context.Context) error {
+	if a.client == nil {
+		return errors.New("client is not connected")
+	}
+
+	return a.client.Ping(ctx, nil)
+}
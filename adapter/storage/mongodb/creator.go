@@ -0,0 +1,25 @@
+package mongodb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/radianteam/framework/adapter"
+	"github.com/radianteam/framework/adapter/util/config"
+)
+
+// Function creates the MongoDB adapter from a configuration section.
+func MongoDbAdapterCreate(name string, configAdapter *config.ConfigAdapter) (adapter.AdapterInterface, error) {
+	if configAdapter == nil {
+		return nil, errors.New("configuration adapter is not provided")
+	}
+
+	adapterConfig := &MongoDbConfig{}
+	err := configAdapter.Unmarshal(adapterConfig, false)
+
+	if err != nil {
+		return nil, fmt.Errorf("configuration loading error for the adapter with name %s: %v", name, err)
+	}
+
+	return NewMongoDbAdapter(name, adapterConfig), nil
+}
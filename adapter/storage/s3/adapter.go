@@ -1,6 +1,8 @@
 package s3
 
 import (
+	"context"
+	"errors"
 	"io"
 	"os"
 
@@ -113,9 +115,13 @@ func (a *AwsS3Adapter) BucketItemList(name string, prefix string) ([]*s3.Object,
 }
 
 func (a *AwsS3Adapter) BucketItemUpload(bucket string, key string, body io.Reader) (err error) {
+	return a.BucketItemUploadContext(context.Background(), bucket, key, body)
+}
+
+func (a *AwsS3Adapter) BucketItemUploadContext(ctx context.Context, bucket string, key string, body io.Reader) (err error) {
 	uploader := s3manager.NewUploader(a.awsSession)
 
-	_, err = uploader.Upload(&s3manager.UploadInput{
+	_, err = uploader.UploadWithContext(ctx, &s3manager.UploadInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
 		Body:   body,
@@ -130,9 +136,13 @@ func (a *AwsS3Adapter) BucketItemUpload(bucket string, key string, body io.Reade
 }
 
 func (a *AwsS3Adapter) BucketItemDownload(bucket string, key string, body io.WriterAt) (bytes int64, err error) {
+	return a.BucketItemDownloadContext(context.Background(), bucket, key, body)
+}
+
+func (a *AwsS3Adapter) BucketItemDownloadContext(ctx context.Context, bucket string, key string, body io.WriterAt) (bytes int64, err error) {
 	downloader := s3manager.NewDownloader(a.awsSession)
 
-	bytes, err = downloader.Download(body,
+	bytes, err = downloader.DownloadWithContext(ctx, body,
 		&s3.GetObjectInput{
 			Bucket: aws.String(bucket),
 			Key:    aws.String(key),
@@ -238,3 +248,14 @@ func (a *AwsS3Adapter) BucketDelete(name string, wait bool) (err error) {
 
 // TODO: implement restore item
 // TODO: implement copy item
+
+// Function checks that the storage is reachable.
+func (a *AwsS3Adapter) HealthCheck(ctx context.Context) (err error) {
+	if a.s3Client == nil {
+		return errors.New("client is not configured")
+	}
+
+	_, err = a.s3Client.ListBucketsWithContext(ctx, &s3.ListBucketsInput{})
+
+	return
+}
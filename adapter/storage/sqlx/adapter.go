@@ -1,6 +1,9 @@
 package sqlx
 
 import (
+	"context"
+	"errors"
+
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
@@ -8,8 +11,8 @@ import (
 )
 
 type SqlxConfig struct {
-	Driver           string `json:"Driver" config:"Driver,required"`
-	ConnectionString string `json:"ConnectionString,omitempty" config:"ConnectionString,required"`
+	Driver           string `json:"Driver" config:"Driver,required,nonempty"`
+	ConnectionString string `json:"ConnectionString,omitempty" config:"ConnectionString,required,nonempty"`
 }
 
 type SqlxAdapter struct {
@@ -48,3 +51,18 @@ func (a *SqlxAdapter) Get() *sqlx.DB {
 func (c *SqlxAdapter) Begin() (*sqlx.Tx, error) {
 	return c.db.Beginx()
 }
+
+// Function starts a transaction which is rolled back if the
+// context is cancelled before commit.
+func (c *SqlxAdapter) BeginContext(ctx context.Context) (*sqlx.Tx, error) {
+	return c.db.BeginTxx(ctx, nil)
+}
+
+// Function pings the database.
+func (a *SqlxAdapter) HealthCheck(ctx context.Context) error {
+	if a.db == nil {
+		return errors.New("database is not connected")
+	}
+
+	return a.db.PingContext(ctx)
+}
@@ -1,19 +1,15 @@
 package config
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
-	"reflect"
-	"strconv"
 	"strings"
-	"syscall"
+	"sync"
 
 	"golang.org/x/exp/slices"
 
 	"github.com/radianteam/framework/adapter"
-	"golang.org/x/exp/maps"
 )
 
 const DefaultJsonConfigPath string = "config.json"
@@ -24,89 +20,197 @@ const TagConfigRequiredName = "required"
 type ConfigAdapter struct {
 	*adapter.BaseAdapter
 
+	mutex  sync.RWMutex
 	config map[string]any
+
+	// sources merged by precedence and replayed by Reload()
+	sources []*configSource
+	files   []string
+
+	decodeHooks     []DecodeHookFunc
+	secretProviders map[string]SecretProvider
+	cipher          *ValueCipher
+
+	// name of the environment profile like "production"
+	profile string
+
+	subMutex    sync.Mutex
+	subscribers []*subscription
+
+	// sections returned by GetAdapter() keep the root adapter
+	// and their path for subscriptions
+	root   *ConfigAdapter
+	prefix []string
 }
 
 func NewConfigAdapter(name string) *ConfigAdapter {
 	return &ConfigAdapter{BaseAdapter: adapter.NewBaseAdapter(name), config: make(map[string]any)}
 }
 
+// Function merges a JSON document into the base file layer.
+// Comments and trailing commas are allowed.
 func (a *ConfigAdapter) LoadFromJson(cfgStr []byte) error {
-	return json.Unmarshal(cfgStr, &a.config)
+	return a.loadDocument(FormatJson, cfgStr)
+}
+
+// Function merges a YAML document into the base file layer.
+func (a *ConfigAdapter) LoadFromYaml(cfgStr []byte) error {
+	return a.loadDocument(FormatYaml, cfgStr)
+}
+
+// Function merges a TOML document into the base file layer.
+func (a *ConfigAdapter) LoadFromToml(cfgStr []byte) error {
+	return a.loadDocument(FormatToml, cfgStr)
+}
+
+func (a *ConfigAdapter) loadDocument(format string, cfgStr []byte) error {
+	a.mutex.RLock()
+	name := fmt.Sprintf("%s:%d", format, len(a.sources))
+	a.mutex.RUnlock()
+
+	return a.AddSource(name, LayerFile, func() (map[string]any, error) {
+		return parseConfig(format, cfgStr)
+	})
 }
 
+// Function loads a base configuration file. The format is selected
+// by the extension: .json, .jsonc, .yaml, .yml or .toml. Several
+// files are merged in the order of loading.
+func (a *ConfigAdapter) LoadFromFile(filePath string) error {
+	return a.loadFile(filePath, LayerFile, "")
+}
+
+// Function loads a base configuration file in the JSON format
+// whatever its extension is.
 func (a *ConfigAdapter) LoadFromFileJson(filePath string) error {
+	return a.loadFile(filePath, LayerFile, FormatJson)
+}
+
+// Function loads an environment specific file overriding base
+// files (e.g. config.production.yaml over config.yaml). The format
+// is selected by the extension.
+func (a *ConfigAdapter) LoadOverlayFromFile(filePath string) error {
+	return a.loadFile(filePath, LayerOverlay, "")
+}
+
+// Function loads a file into a section, e.g. a file of a
+// microservice into its section of the main configuration. The
+// format is selected by the extension.
+func (a *ConfigAdapter) LoadSectionFromFile(filePath string, layer ConfigLayer, path ...string) error {
+	return a.loadFile(filePath, layer, "", path...)
+}
+
+func (a *ConfigAdapter) loadFile(filePath string, layer ConfigLayer, format string, path ...string) (err error) {
 	if strings.TrimSpace(filePath) == "" {
 		filePath = DefaultJsonConfigPath
 	}
 
-	cnf := make(map[string]any)
+	if format == "" {
+		if format, err = FormatFromPath(filePath); err != nil {
+			return err
+		}
+	}
 
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		a.Logger.Warningf("Cannot read file %s - %s", filePath, err)
-		return err
+	name := layer.String() + ":" + filePath
+
+	if len(path) > 0 {
+		name += ":" + strings.Join(path, ".")
 	}
 
-	err = json.Unmarshal(data, &cnf)
+	err = a.AddSource(name, layer, func() (map[string]any, error) {
+		cnf, err := a.loadFromFile(filePath, format)
+
+		if err != nil || len(path) == 0 {
+			return cnf, err
+		}
+
+		section := map[string]any{}
+
+		return section, setPath(section, cnf, path)
+	})
 
 	if err != nil {
-		a.Logger.Errorf("Cannot apply config from file %s - %s", filePath, err)
+		return err
 	}
 
-	a.config = cnf // TODO: remove and make union with previous configuration
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
 
-	return err
-}
+	if !slices.Contains(a.files, filePath) {
+		a.files = append(a.files, filePath)
+	}
 
-func (a *ConfigAdapter) LoadFromEnv(prefix string) error {
-	return a.loadMap(syscall.Environ(), prefix, "=", "_")
+	return nil
 }
 
-func (a *ConfigAdapter) LoadFromArgs(prefix string) error {
-	if len(os.Args) < 2 {
-		return nil
+func (a *ConfigAdapter) loadFromFile(filePath string, format string) (map[string]any, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		a.Logger.Warningf("Cannot read file %s - %s", filePath, err)
+		return nil, err
 	}
 
-	args := os.Args[1:]
+	cnf, err := parseConfig(format, data)
+
+	if err != nil {
+		a.Logger.Errorf("Cannot apply config from file %s - %s", filePath, err)
+
+		return nil, fmt.Errorf("file %s: %w", filePath, err)
+	}
 
-	return a.loadMap(args, prefix, "=", "-")
+	return cnf, nil
 }
 
-func (a *ConfigAdapter) loadMap(data []string, prefix, delimKeyValue, delimParams string) error {
-	for _, arg := range data {
-		if !strings.HasPrefix(arg, prefix) {
-			continue
-		}
+// Function sets the profile of the environment in the root adapter,
+// e.g. "production". Creators of adapters and workers read it from
+// their sections.
+func (a *ConfigAdapter) SetProfile(profile string) {
+	root := a.getRoot()
 
-		param := arg[len(prefix)+len(delimParams):]
+	root.mutex.Lock()
+	defer root.mutex.Unlock()
 
-		splitIndex := strings.Index(param, delimKeyValue)
+	root.profile = profile
+}
 
-		kv := param[splitIndex+len(delimKeyValue):]
-		keys := strings.Split(param[:splitIndex], delimParams)
+// Function returns the profile of the environment or an empty
+// string.
+func (a *ConfigAdapter) GetProfile() string {
+	root := a.getRoot()
 
-		if err := a.SetValue(keys, kv); err != nil {
-			return err
-		}
-	}
+	root.mutex.RLock()
+	defer root.mutex.RUnlock()
 
-	return nil
+	return root.profile
 }
 
+// Function returns an adapter of a section. The section is a deep
+// copy, so values set in it do not change the parent adapter.
 func (a *ConfigAdapter) GetAdapter(path ...string) (*ConfigAdapter, error) {
 	ac := NewConfigAdapter(a.GetName())
 
-	m, err := a.GetValue(path...)
+	a.mutex.RLock()
+	m, err := walkPath(a.config, path)
+
+	if err == nil {
+		m = cloneValue(m)
+	}
+
+	a.mutex.RUnlock()
+
 	if err != nil {
 		return nil, err
 	}
 
-	if reflect.TypeOf(m).Kind() != reflect.TypeOf(map[string]any{}).Kind() {
+	section, ok := m.(map[string]any)
+
+	if !ok {
 		return nil, errors.New("invalid config")
 	}
 
-	ac.config = maps.Clone(m.(map[string]any))
+	ac.config = section
+	ac.root = a.getRoot()
+	ac.prefix = append(slices.Clone(a.prefix), path...)
 
 	return ac, nil
 }
@@ -151,84 +255,79 @@ func (a *ConfigAdapter) GetString(path ...string) (string, error) {
 	return fmt.Sprint(result), nil
 }
 
+// Function returns the value of the path. Items of lists are
+// addressed by their indexes, e.g. GetValue("Brokers", "0", "Host").
+// Integers of files are int64, other numbers are float64.
 func (a *ConfigAdapter) GetValue(path ...string) (any, error) {
-	if len(path) == 0 {
-		return a.config, nil
-	}
-
-	n := a.config
-
-	for idx, value := range path {
-		if idx == len(path)-1 {
-			break
-		}
-
-		if _, ok := n[value]; !ok {
-			return nil, errors.New("path not valid: " + strings.Join(append(path[:idx], value), "."))
-		}
-
-		if reflect.TypeOf(n).Kind() != reflect.TypeOf(n[value]).Kind() {
-			return nil, errors.New("wrong configuration for " + strings.Join(append(path[:idx], value), "."))
-		}
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
 
-		n = n[value].(map[string]any)
-	}
-
-	result, ok := n[path[len(path)-1]]
-
-	if !ok {
-		return nil, errors.New("value not found: " + path[len(path)-1] + " for path " + strings.Join(path, "."))
-	}
-
-	return result, nil
+	return walkPath(a.config, path)
 }
 
+// Function sets a value creating missing sections. Values set in
+// the root adapter have the highest precedence, references in them
+// are resolved and they are kept when other sources are loaded or
+// reloaded. Values set in a section adapter change only the section.
 func (a *ConfigAdapter) SetValue(val any, path ...string) error {
 	if len(path) == 0 {
 		return nil
 	}
 
-	n := a.config
-
-	for idx, value := range path {
-		if idx == len(path)-1 {
-			break
-		}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
 
-		if _, ok := n[value]; !ok {
-			n[value] = make(map[string]any)
-		}
+	if a.root != nil {
+		return setPath(a.config, val, path)
+	}
 
-		if reflect.TypeOf(n).Kind() != reflect.TypeOf(n[value]).Kind() {
-			return errors.New("wrong configuration for " + strings.Join(append(path[:idx], value), "."))
-		}
+	resolved, err := a.resolveValue(path, cloneValue(val))
 
-		n = n[value].(map[string]any)
+	if err != nil {
+		return err
 	}
 
-	n[path[len(path)-1]] = val
+	if err = setPath(a.config, resolved, path); err != nil {
+		return err
+	}
 
-	return nil
+	return a.setRuntimeValue(cloneValue(val), path)
 }
 
+// Function decodes the configuration into a structure. Fields are
+// matched by the "config" tag which can be a dotted path. Nested
+// structures, pointers, maps, slices, durations and TextUnmarshaler
+// types are supported. Errors of all fields are returned together
+// with dotted paths of the values.
 func (a *ConfigAdapter) Unmarshal(destination interface{}, skipRequired bool) error {
-	return a.unmarshalFromMap(a.config, destination, skipRequired)
+	a.mutex.RLock()
+	source := cloneValue(a.config).(map[string]any)
+	a.mutex.RUnlock()
+
+	return a.unmarshalFromMap(a.prefix, source, destination, skipRequired)
 }
 
+// Function decodes a section of the configuration like Unmarshal().
 func (a *ConfigAdapter) UnmarshalPath(destination interface{}, skipRequired bool, path ...string) error {
 	m, err := a.GetValue(path...)
 	if err != nil {
 		return err
 	}
 
-	if reflect.TypeOf(m).Kind() != reflect.TypeOf(map[string]any{}).Kind() {
+	section, ok := m.(map[string]any)
+
+	if !ok {
 		return errors.New("invalid config: wrong param type for path " + strings.Join(path, "."))
 	}
 
-	return a.unmarshalFromMap(m.(map[string]any), destination, skipRequired)
+	a.mutex.RLock()
+	source := cloneValue(section).(map[string]any)
+	a.mutex.RUnlock()
+
+	return a.unmarshalFromMap(append(slices.Clone(a.prefix), path...), source, destination, skipRequired)
 }
 
-func (a *ConfigAdapter) unmarshalFromMap(source map[string]any, destination interface{}, skipRequired bool) error {
+func (a *ConfigAdapter) unmarshalFromMap(path []string, source map[string]any, destination interface{}, skipRequired bool) error {
 	if source == nil {
 		return errors.New("empty config")
 	}
@@ -237,109 +336,9 @@ func (a *ConfigAdapter) unmarshalFromMap(source map[string]any, destination inte
 		return errors.New("empty structure")
 	}
 
-	valueOfDest := reflect.ValueOf(destination).Elem()
-	typeOfDest := valueOfDest.Type()
-
-	for i := 0; i < typeOfDest.NumField(); i++ {
-		fieldTagString, ok := typeOfDest.Field(i).Tag.Lookup(TagConfigName)
-
-		if !ok || fieldTagString == "" {
-			a.Logger.Debugf("Field '%s' has no tag = '%s'. Skip", typeOfDest.Field(i).Name, TagConfigName)
-			continue
-		}
-
-		fieldTags := strings.Split(fieldTagString, ",")
-
-		sourceValue, ok := source[fieldTags[0]]
-
-		if !ok {
-			a.Logger.Debugf("Field '%s' is not in config. Skip", fieldTags[0])
-
-			if !skipRequired && slices.Contains(fieldTags, TagConfigRequiredName) {
-				return fmt.Errorf("field '%s' is required", typeOfDest.Field(i).Name)
-			}
-
-			continue
-		}
-
-		if valueOfDest.Field(i).CanSet() {
-			if valueOfDest.Field(i).Kind() == reflect.Slice {
-				for _, sliceVal := range sourceValue.([]interface{}) {
-					valueOfDest.Field(i).Set(reflect.Append(valueOfDest.Field(i), reflect.ValueOf(sliceVal)))
-				}
-				continue
-			}
-
-			sourceReflectValue := reflect.ValueOf(sourceValue)
-
-			if (valueOfDest.Field(i).Kind() == reflect.Int ||
-				valueOfDest.Field(i).Kind() == reflect.Int16 ||
-				valueOfDest.Field(i).Kind() == reflect.Int32 ||
-				valueOfDest.Field(i).Kind() == reflect.Int64 ||
-				valueOfDest.Field(i).Kind() == reflect.Int8) &&
-				sourceReflectValue.Kind() == reflect.String {
-				intVal, err := strconv.ParseInt(sourceReflectValue.String(), 10, int(valueOfDest.Field(i).Type().Size())*8)
-
-				if err != nil {
-					return err
-				}
-
-				valueOfDest.Field(i).Set(reflect.ValueOf(intVal).Convert(valueOfDest.Field(i).Type()))
-
-				continue
-			}
-
-			if (valueOfDest.Field(i).Kind() == reflect.Uint ||
-				valueOfDest.Field(i).Kind() == reflect.Uint16 ||
-				valueOfDest.Field(i).Kind() == reflect.Uint32 ||
-				valueOfDest.Field(i).Kind() == reflect.Uint64 ||
-				valueOfDest.Field(i).Kind() == reflect.Uint8) &&
-				sourceReflectValue.Kind() == reflect.String {
-				uintVal, err := strconv.ParseUint(sourceReflectValue.String(), 10, int(valueOfDest.Field(i).Type().Size())*8)
-
-				if err != nil {
-					return err
-				}
+	d := &decoder{hooks: a.getDecodeHooks(), skipRequired: skipRequired, logger: a.Logger}
 
-				valueOfDest.Field(i).Set(reflect.ValueOf(uintVal).Convert(valueOfDest.Field(i).Type()))
-
-				continue
-			}
-
-			if valueOfDest.Field(i).Kind() == reflect.Bool &&
-				sourceReflectValue.Kind() == reflect.String {
-				boolVal, err := strconv.ParseBool(sourceReflectValue.String())
-
-				if err != nil {
-					return err
-				}
-
-				valueOfDest.Field(i).Set(reflect.ValueOf(boolVal).Convert(valueOfDest.Field(i).Type()))
-
-				continue
-			}
-
-			if (valueOfDest.Field(i).Kind() == reflect.Float32 ||
-				valueOfDest.Field(i).Kind() == reflect.Float64) &&
-				sourceReflectValue.Kind() == reflect.String {
-				floatVal, err := strconv.ParseFloat(sourceReflectValue.String(), int(valueOfDest.Field(i).Type().Size())*8)
-
-				if err != nil {
-					return err
-				}
-
-				valueOfDest.Field(i).Set(reflect.ValueOf(floatVal).Convert(valueOfDest.Field(i).Type()))
-
-				continue
-			}
-
-			valueOfDest.Field(i).Set(sourceReflectValue.Convert(valueOfDest.Field(i).Type()))
-		} else {
-			return fmt.Errorf("field '%s' cannot be set", typeOfDest.Field(i).Name)
-		}
-	}
-
-	return nil
+	return d.decodeRoot(path, source, destination)
 }
 
 func (a *ConfigAdapter) Setup() (err error) {
@@ -347,6 +346,9 @@ func (a *ConfigAdapter) Setup() (err error) {
 }
 
 func (a *ConfigAdapter) Close() error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
 	for k := range a.config {
 		delete(a.config, k)
 	}
@@ -0,0 +1,213 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// Default separators of nested keys, e.g. RADIAN_ADAPTERS_DB_PORT
+// and --radian-adapters-db-port. Use a double separator like "__"
+// when keys contain the single one.
+const (
+	DefaultEnvSeparator  = "_"
+	DefaultArgsSeparator = "-"
+)
+
+// Function loads environment variables with the prefix. They
+// override configuration files. Keys are matched with existing keys
+// case-insensitively, so RADIAN_ADAPTERS_DB_PORT overrides
+// "Adapters.Db.Port".
+func (a *ConfigAdapter) LoadFromEnv(prefix string) error {
+	return a.LoadFromEnvWithSeparator(prefix, DefaultEnvSeparator)
+}
+
+// Function loads environment variables like LoadFromEnv() with a
+// custom separator of nested keys.
+func (a *ConfigAdapter) LoadFromEnvWithSeparator(prefix string, separator string) error {
+	if separator == "" {
+		return fmt.Errorf("separator of keys for the prefix %s is empty", prefix)
+	}
+
+	return a.addSource(LayerEnv.String()+":"+prefix, LayerEnv, func() (map[string]any, error) {
+		return bindEnv(os.Environ(), prefix, separator)
+	}, true)
+}
+
+// Function loads command line arguments with the prefix. They
+// override all other sources. Arguments are set like
+// --radian-adapters-db-port=5432 or --radian-adapters-db-port 5432,
+// an argument without a value is true. Keys are matched with
+// existing keys case-insensitively.
+func (a *ConfigAdapter) LoadFromArgs(prefix string) error {
+	return a.LoadFromArgsWithSeparator(prefix, DefaultArgsSeparator)
+}
+
+// Function loads command line arguments like LoadFromArgs() with a
+// custom separator of nested keys.
+func (a *ConfigAdapter) LoadFromArgsWithSeparator(prefix string, separator string) error {
+	if separator == "" {
+		return fmt.Errorf("separator of keys for the prefix %s is empty", prefix)
+	}
+
+	return a.addSource(LayerArgs.String()+":"+prefix, LayerArgs, func() (map[string]any, error) {
+		if len(os.Args) < 2 {
+			return map[string]any{}, nil
+		}
+
+		return bindArgs(os.Args[1:], prefix, separator)
+	}, true)
+}
+
+// Function converts "NAME=value" entries of the environment.
+func bindEnv(environ []string, prefix string, separator string) (map[string]any, error) {
+	cnf := make(map[string]any)
+
+	for _, entry := range environ {
+		name, value, ok := strings.Cut(entry, "=")
+
+		keys, matched := bindKeys(name, prefix, separator)
+
+		if !matched {
+			continue
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s has no value", name)
+		}
+
+		if err := bindValue(cnf, name, keys, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return cnf, nil
+}
+
+// Function converts "--key=value", "--key value" and "--key"
+// arguments. Parsing stops at "--".
+func bindArgs(args []string, prefix string, separator string) (map[string]any, error) {
+	cnf := make(map[string]any)
+
+	for idx := 0; idx < len(args); idx++ {
+		arg := args[idx]
+
+		if arg == "--" {
+			break
+		}
+
+		name, value, ok := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+
+		keys, matched := bindKeys(name, prefix, separator)
+
+		if !matched {
+			continue
+		}
+
+		if !ok && !strings.HasPrefix(arg, "-") {
+			return nil, fmt.Errorf("argument %s has no value, use %s=value", arg, arg)
+		}
+
+		if !ok {
+			value = "true"
+
+			if idx+1 < len(args) && !strings.HasPrefix(args[idx+1], "--") {
+				value = args[idx+1]
+				idx++
+			}
+		}
+
+		if err := bindValue(cnf, name, keys, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return cnf, nil
+}
+
+// Function removes the prefix and splits the name by the separator.
+// The prefix is matched case-insensitively and must be followed by
+// the separator.
+func bindKeys(name string, prefix string, separator string) ([]string, bool) {
+	if prefix != "" {
+		if len(name) <= len(prefix)+len(separator) || !strings.EqualFold(name[:len(prefix)], prefix) || name[len(prefix):len(prefix)+len(separator)] != separator {
+			return nil, false
+		}
+
+		name = name[len(prefix)+len(separator):]
+	}
+
+	if name == "" {
+		return nil, false
+	}
+
+	return strings.Split(name, separator), true
+}
+
+// Function sets a value of a variable or an argument. Values in
+// brackets or braces are parsed as JSON lists and sections, other
+// values are strings converted by getters and Unmarshal().
+func bindValue(cnf map[string]any, name string, keys []string, value string) error {
+	if slices.Contains(keys, "") {
+		return fmt.Errorf("%s has an empty key", name)
+	}
+
+	var parsed any = value
+
+	if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "[") || strings.HasPrefix(trimmed, "{") {
+		if err := decodeJson([]byte(trimmed), &parsed); err != nil {
+			return fmt.Errorf("%s has a wrong JSON value: %v", name, err)
+		}
+
+		parsed = normalizeItem(parsed)
+	}
+
+	if _, ok := lookupPath(cnf, keys); ok {
+		return fmt.Errorf("%s sets the key %s which is already set", name, strings.Join(keys, "."))
+	}
+
+	if err := setPath(cnf, parsed, keys); err != nil {
+		return fmt.Errorf("%s conflicts with another value: %v", name, err)
+	}
+
+	return nil
+}
+
+// Function merges a source with keys matched case-insensitively. A
+// key which is not found keeps its spelling.
+func mergeMapsFold(dst map[string]any, src map[string]any) {
+	for key, value := range src {
+		dstKey := foldKey(dst, key)
+
+		srcMap, srcOk := value.(map[string]any)
+		dstMap, dstOk := dst[dstKey].(map[string]any)
+
+		if srcOk && dstOk {
+			mergeMapsFold(dstMap, srcMap)
+
+			continue
+		}
+
+		dst[dstKey] = cloneValue(value)
+	}
+}
+
+// Function returns the key of the map equal to the key ignoring the
+// case. An exact match is preferred.
+func foldKey(m map[string]any, key string) string {
+	if _, ok := m[key]; ok {
+		return key
+	}
+
+	keys := maps.Keys(m)
+	slices.Sort(keys)
+
+	if idx := slices.IndexFunc(keys, func(k string) bool { return strings.EqualFold(k, key) }); idx >= 0 {
+		return keys[idx]
+	}
+
+	return key
+}
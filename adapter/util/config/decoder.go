@@ -0,0 +1,575 @@
+package config
+
+import (
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// Function converts a configuration value before it is decoded into
+// a field of the target type. Return the value unchanged to let the
+// decoder handle it.
+type DecodeHookFunc func(value any, target reflect.Type) (any, error)
+
+// Error of a field which cannot be decoded. The path is the dotted
+// path of the value in the configuration.
+type FieldError struct {
+	Path string
+	Err  error
+}
+
+func (e *FieldError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// Error of a missing value of a field with the "required" flag.
+var ErrRequired = errors.New("value is required")
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// AddDecodeHook registers a function called for every value decoded
+// by Unmarshal() and UnmarshalPath(). Hooks are registered in the
+// root adapter and are shared by its sections.
+func (a *ConfigAdapter) AddDecodeHook(hook DecodeHookFunc) {
+	root := a.getRoot()
+
+	root.mutex.Lock()
+	defer root.mutex.Unlock()
+
+	root.decodeHooks = append(root.decodeHooks, hook)
+}
+
+func (a *ConfigAdapter) getDecodeHooks() []DecodeHookFunc {
+	root := a.getRoot()
+
+	root.mutex.RLock()
+	defer root.mutex.RUnlock()
+
+	return slices.Clone(root.decodeHooks)
+}
+
+// Structure decodes configuration trees into structures and
+// collects errors of all fields.
+type decoder struct {
+	hooks        []DecodeHookFunc
+	skipRequired bool
+	logger       *logrus.Entry
+
+	errs []error
+}
+
+// Function decodes a section into a structure. The destination
+// must be a non-nil pointer to a structure.
+func (d *decoder) decodeRoot(path []string, source map[string]any, destination any) error {
+	value := reflect.ValueOf(destination)
+
+	if value.Kind() != reflect.Pointer || value.IsNil() || value.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("destination must be a non-nil pointer to a structure, got %T", destination)
+	}
+
+	d.decodeStruct(path, source, value.Elem())
+
+	return errors.Join(d.errs...)
+}
+
+func (d *decoder) fail(path []string, err error) {
+	d.errs = append(d.errs, &FieldError{Path: strings.Join(path, "."), Err: err})
+}
+
+// Function decodes fields with the "config" tag. The tag name can be
+// a dotted path inside the section. Embedded structures without the
+// tag are decoded from the same section.
+func (d *decoder) decodeStruct(path []string, source map[string]any, dest reflect.Value) {
+	typeOfDest := dest.Type()
+
+	for i := 0; i < typeOfDest.NumField(); i++ {
+		field := typeOfDest.Field(i)
+		fieldTagString, ok := field.Tag.Lookup(TagConfigName)
+
+		if field.Anonymous && fieldTagString == "" {
+			d.decodeEmbedded(path, source, dest.Field(i))
+
+			continue
+		}
+
+		if !ok || fieldTagString == "" {
+			d.logger.Debugf("Field '%s' has no tag = '%s'. Skip", field.Name, TagConfigName)
+			continue
+		}
+
+		tag, err := parseTag(fieldTagString)
+		fieldPath := append(slices.Clone(path), tag.keys...)
+
+		if err != nil {
+			d.fail(fieldPath, err)
+
+			continue
+		}
+
+		if len(tag.unknown) > 0 {
+			d.logger.Warnf("Field '%s' has unknown options %s of the tag '%s'. Skip them", field.Name, strings.Join(tag.unknown, ", "), TagConfigName)
+		}
+
+		if !dest.Field(i).CanSet() {
+			d.fail(fieldPath, fmt.Errorf("field '%s' cannot be set", field.Name))
+
+			continue
+		}
+
+		sourceValue, ok := lookupPath(source, tag.keys)
+
+		switch {
+		case ok:
+			d.decode(fieldPath, sourceValue, dest.Field(i))
+		case tag.defaultValue != nil:
+			if dest.Field(i).IsZero() {
+				d.decode(fieldPath, *tag.defaultValue, dest.Field(i))
+			}
+
+			ok = true
+		case !d.skipRequired && tag.required:
+			d.fail(fieldPath, ErrRequired)
+
+			continue
+		case isSection(dest.Field(i).Type()):
+			// defaults and rules of a missing section are applied too
+			d.decodeStruct(fieldPath, map[string]any{}, dest.Field(i))
+		default:
+			d.logger.Debugf("Field '%s' is not in config. Skip", strings.Join(tag.keys, "."))
+		}
+
+		d.validate(fieldPath, dest.Field(i), tag.rules, ok)
+	}
+}
+
+func (d *decoder) decodeEmbedded(path []string, source map[string]any, dest reflect.Value) {
+	if dest.Kind() == reflect.Pointer {
+		if dest.Type().Elem().Kind() != reflect.Struct {
+			return
+		}
+
+		if dest.IsNil() {
+			if !dest.CanSet() {
+				return
+			}
+
+			dest.Set(reflect.New(dest.Type().Elem()))
+		}
+
+		dest = dest.Elem()
+	}
+
+	if dest.Kind() == reflect.Struct {
+		d.decodeStruct(path, source, dest)
+	}
+}
+
+// Function decodes a value of any supported type.
+func (d *decoder) decode(path []string, value any, dest reflect.Value) {
+	if dest.Kind() == reflect.Pointer && value != nil {
+		if dest.IsNil() {
+			dest.Set(reflect.New(dest.Type().Elem()))
+		}
+
+		d.decode(path, value, dest.Elem())
+
+		return
+	}
+
+	for _, hook := range d.hooks {
+		var err error
+
+		if value, err = hook(value, dest.Type()); err != nil {
+			d.fail(path, err)
+
+			return
+		}
+	}
+
+	if value == nil {
+		dest.Set(reflect.Zero(dest.Type()))
+
+		return
+	}
+
+	if reflect.PointerTo(dest.Type()).Implements(textUnmarshalerType) {
+		if text, ok := value.(string); ok {
+			if err := dest.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); err != nil {
+				d.fail(path, err)
+			}
+
+			return
+		}
+	}
+
+	if dest.Type() == durationType {
+		duration, err := toDuration(value)
+
+		if err != nil {
+			d.fail(path, err)
+		} else {
+			dest.SetInt(int64(duration))
+		}
+
+		return
+	}
+
+	switch dest.Kind() {
+	case reflect.Struct:
+		section, ok := value.(map[string]any)
+
+		if !ok {
+			d.fail(path, fmt.Errorf("expected a section, got %s", describe(value)))
+
+			return
+		}
+
+		d.decodeStruct(path, section, dest)
+	case reflect.Map:
+		d.decodeMap(path, value, dest)
+	case reflect.Slice:
+		if text, ok := value.(string); ok && dest.Type().Elem().Kind() == reflect.Uint8 {
+			dest.SetBytes([]byte(text))
+
+			return
+		}
+
+		items, err := toList(value)
+
+		if err != nil {
+			d.fail(path, err)
+
+			return
+		}
+
+		slice := reflect.MakeSlice(dest.Type(), len(items), len(items))
+
+		for idx, item := range items {
+			d.decode(append(slices.Clone(path), strconv.Itoa(idx)), item, slice.Index(idx))
+		}
+
+		dest.Set(slice)
+	case reflect.Array:
+		items, err := toList(value)
+
+		if err != nil {
+			d.fail(path, err)
+
+			return
+		}
+
+		if len(items) > dest.Len() {
+			d.fail(path, fmt.Errorf("expected at most %d items, got %d", dest.Len(), len(items)))
+
+			return
+		}
+
+		for idx, item := range items {
+			d.decode(append(slices.Clone(path), strconv.Itoa(idx)), item, dest.Index(idx))
+		}
+	case reflect.Interface:
+		sourceValue := reflect.ValueOf(value)
+
+		if !sourceValue.Type().AssignableTo(dest.Type()) {
+			d.fail(path, fmt.Errorf("cannot use %s as %s", describe(value), dest.Type()))
+
+			return
+		}
+
+		dest.Set(sourceValue)
+	default:
+		if err := setScalar(dest, value); err != nil {
+			d.fail(path, err)
+		}
+	}
+}
+
+func (d *decoder) decodeMap(path []string, value any, dest reflect.Value) {
+	section, ok := value.(map[string]any)
+
+	if !ok {
+		d.fail(path, fmt.Errorf("expected a section, got %s", describe(value)))
+
+		return
+	}
+
+	if dest.IsNil() {
+		dest.Set(reflect.MakeMapWithSize(dest.Type(), len(section)))
+	}
+
+	keys := maps.Keys(section)
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		itemPath := append(slices.Clone(path), key)
+
+		mapKey := reflect.New(dest.Type().Key()).Elem()
+
+		if err := setScalar(mapKey, key); err != nil {
+			d.fail(itemPath, fmt.Errorf("wrong key: %w", err))
+
+			continue
+		}
+
+		item := reflect.New(dest.Type().Elem()).Elem()
+
+		d.decode(itemPath, section[key], item)
+
+		dest.SetMapIndex(mapKey, item)
+	}
+}
+
+// Function sets a string, boolean or numeric value. Strings are
+// parsed because environment variables and arguments are strings.
+func setScalar(dest reflect.Value, value any) error {
+	switch dest.Kind() {
+	case reflect.String:
+		switch v := value.(type) {
+		case string:
+			dest.SetString(v)
+		case bool, float64, float32, int, int64, int32, int16, int8, uint, uint64, uint32, uint16, uint8:
+			dest.SetString(fmt.Sprint(v))
+		default:
+			return fmt.Errorf("cannot use %s as %s", describe(value), dest.Type())
+		}
+	case reflect.Bool:
+		switch v := value.(type) {
+		case bool:
+			dest.SetBool(v)
+		case string:
+			boolVal, err := strconv.ParseBool(v)
+
+			if err != nil {
+				return fmt.Errorf("cannot use %q as %s", v, dest.Type())
+			}
+
+			dest.SetBool(boolVal)
+		default:
+			return fmt.Errorf("cannot use %s as %s", describe(value), dest.Type())
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		number, err := toInt(value, dest.Type())
+
+		if err != nil {
+			return err
+		}
+
+		if dest.OverflowInt(number) {
+			return fmt.Errorf("%v overflows %s", number, dest.Type())
+		}
+
+		dest.SetInt(number)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		number, err := toUint(value, dest.Type())
+
+		if err != nil {
+			return err
+		}
+
+		if dest.OverflowUint(number) {
+			return fmt.Errorf("%v overflows %s", number, dest.Type())
+		}
+
+		dest.SetUint(number)
+	case reflect.Float32, reflect.Float64:
+		number, err := toFloat(value, dest.Type())
+
+		if err != nil {
+			return err
+		}
+
+		if dest.OverflowFloat(number) {
+			return fmt.Errorf("%v overflows %s", number, dest.Type())
+		}
+
+		dest.SetFloat(number)
+	default:
+		return fmt.Errorf("unsupported field type %s", dest.Type())
+	}
+
+	return nil
+}
+
+func toFloat(value any, target reflect.Type) (float64, error) {
+	if text, ok := value.(string); ok {
+		number, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+
+		if err != nil {
+			return 0, fmt.Errorf("cannot use %q as %s", text, target)
+		}
+
+		return number, nil
+	}
+
+	sourceValue := reflect.ValueOf(value)
+
+	switch sourceValue.Kind() {
+	case reflect.Float32, reflect.Float64:
+		return sourceValue.Float(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(sourceValue.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(sourceValue.Uint()), nil
+	}
+
+	return 0, fmt.Errorf("cannot use %s as %s", describe(value), target)
+}
+
+// Function converts a value to a signed integer without passing
+// integers through float64, so large values keep their precision.
+// Floats and strings like "1e3" are accepted if they are integers.
+func toInt(value any, target reflect.Type) (int64, error) {
+	if text, ok := value.(string); ok {
+		number, err := strconv.ParseInt(strings.TrimSpace(text), 10, 64)
+
+		if err == nil {
+			return number, nil
+		}
+
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, fmt.Errorf("%s overflows %s", text, target)
+		}
+	}
+
+	sourceValue := reflect.ValueOf(value)
+
+	switch sourceValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return sourceValue.Int(), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if sourceValue.Uint() > math.MaxInt64 {
+			return 0, fmt.Errorf("%v overflows %s", value, target)
+		}
+
+		return int64(sourceValue.Uint()), nil
+	}
+
+	number, err := toFloat(value, target)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if number != math.Trunc(number) {
+		return 0, fmt.Errorf("%v is not an integer", number)
+	}
+
+	// float64(math.MaxInt64) is 2^63 which overflows int64
+	if number < math.MinInt64 || number >= math.MaxInt64 {
+		return 0, fmt.Errorf("%v overflows %s", number, target)
+	}
+
+	return int64(number), nil
+}
+
+// Function converts a value to an unsigned integer like toInt().
+func toUint(value any, target reflect.Type) (uint64, error) {
+	if text, ok := value.(string); ok {
+		number, err := strconv.ParseUint(strings.TrimSpace(text), 10, 64)
+
+		if err == nil {
+			return number, nil
+		}
+
+		if errors.Is(err, strconv.ErrRange) {
+			return 0, fmt.Errorf("%s overflows %s", text, target)
+		}
+	}
+
+	sourceValue := reflect.ValueOf(value)
+
+	switch sourceValue.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if sourceValue.Int() < 0 {
+			return 0, fmt.Errorf("%v overflows %s", value, target)
+		}
+
+		return uint64(sourceValue.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return sourceValue.Uint(), nil
+	}
+
+	number, err := toFloat(value, target)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if number != math.Trunc(number) {
+		return 0, fmt.Errorf("%v is not an integer", number)
+	}
+
+	// float64(math.MaxUint64) is 2^64 which overflows uint64
+	if number < 0 || number >= math.MaxUint64 {
+		return 0, fmt.Errorf("%v overflows %s", number, target)
+	}
+
+	return uint64(number), nil
+}
+
+// Function parses a duration like "1m30s". Numbers are seconds.
+func toDuration(value any) (time.Duration, error) {
+	if text, ok := value.(string); ok {
+		if duration, err := time.ParseDuration(text); err == nil {
+			return duration, nil
+		}
+	}
+
+	seconds, err := toFloat(value, durationType)
+
+	if err != nil {
+		return 0, fmt.Errorf("cannot use %s as a duration, use a number of seconds or a string like \"1m30s\"", describe(value))
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// Function returns items of a list. A string is split by commas
+// so lists can be set by environment variables.
+func toList(value any) ([]any, error) {
+	switch v := value.(type) {
+	case []any:
+		return v, nil
+	case string:
+		items := []any{}
+
+		for _, item := range strings.Split(v, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("expected a list, got %s", describe(value))
+}
+
+func describe(value any) string {
+	switch v := value.(type) {
+	case map[string]any:
+		return "a section"
+	case []any:
+		return "a list"
+	case string:
+		return strconv.Quote(v)
+	}
+
+	return fmt.Sprintf("%v (%T)", value, value)
+}
@@ -0,0 +1,194 @@
+package config
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func unmarshalJson(t *testing.T, document string, destination any) error {
+	t.Helper()
+
+	a := NewConfigAdapter("test")
+
+	if err := a.LoadFromJson([]byte(document)); err != nil {
+		t.Fatal(err)
+	}
+
+	return a.Unmarshal(destination, false)
+}
+
+type testServer struct {
+	Host string `config:"Host"`
+	Port int    `config:"Port"`
+}
+
+type testEmbedded struct {
+	Name string `config:"Name"`
+}
+
+type testDecoded struct {
+	testEmbedded
+
+	Port      int            `config:"Server.Port"`
+	Database  *testServer    `config:"Database"`
+	Ports     []int          `config:"Ports"`
+	Hosts     []string       `config:"Hosts"`
+	Servers   []testServer   `config:"Servers"`
+	Pair      [2]int         `config:"Pair"`
+	Labels    map[string]int `config:"Labels"`
+	Timeout   time.Duration  `config:"Timeout"`
+	Interval  time.Duration  `config:"Interval"`
+	Big       int64          `config:"Big"`
+	Unsigned  uint64         `config:"Unsigned"`
+	Ratio     float64        `config:"Ratio"`
+	Enabled   bool           `config:"Enabled"`
+	Untouched string
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		expected testDecoded
+	}{
+		{"nested path", `{"Server": {"Port": 8080}}`, testDecoded{Port: 8080}},
+		{"nested section", `{"Database": {"Host": "db", "Port": "5432"}}`, testDecoded{Database: &testServer{Host: "db", Port: 5432}}},
+		{"embedded structure", `{"Name": "service"}`, testDecoded{testEmbedded: testEmbedded{Name: "service"}}},
+		{"ints from strings", `{"Ports": ["80", "443"]}`, testDecoded{Ports: []int{80, 443}}},
+		{"ints from a comma separated string", `{"Ports": "80, 443"}`, testDecoded{Ports: []int{80, 443}}},
+		{"strings from a comma separated string", `{"Hosts": "a,b"}`, testDecoded{Hosts: []string{"a", "b"}}},
+		{"slice of structures", `{"Servers": [{"Host": "a", "Port": 1}, {"Host": "b"}]}`, testDecoded{Servers: []testServer{{"a", 1}, {"b", 0}}}},
+		{"short array", `{"Pair": [1]}`, testDecoded{Pair: [2]int{1, 0}}},
+		{"map", `{"Labels": {"a": 1, "b": "2"}}`, testDecoded{Labels: map[string]int{"a": 1, "b": 2}}},
+		{"duration in seconds", `{"Timeout": 30, "Interval": 1.5}`, testDecoded{Timeout: 30 * time.Second, Interval: 1500 * time.Millisecond}},
+		{"duration string", `{"Timeout": "30s", "Interval": "1m"}`, testDecoded{Timeout: 30 * time.Second, Interval: time.Minute}},
+		{"int64 precision", `{"Big": 9007199254740993}`, testDecoded{Big: 9007199254740993}},
+		{"uint64 above int64", `{"Unsigned": 18446744073709551615}`, testDecoded{Unsigned: math.MaxUint64}},
+		{"integral float to int", `{"Server": {"Port": 8080.0}}`, testDecoded{Port: 8080}},
+		{"scalars from strings", `{"Ratio": "0.5", "Enabled": "true"}`, testDecoded{Ratio: 0.5, Enabled: true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			actual := testDecoded{}
+
+			if err := unmarshalJson(t, test.document, &actual); err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(actual, test.expected) {
+				t.Errorf("decoded %+v, expected %+v", actual, test.expected)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		path     string
+		message  string
+	}{
+		{"oversized array", `{"Pair": [1, 2, 3]}`, "Pair", "expected at most 2 items, got 3"},
+		{"path of a nested field", `{"Server": {"Port": "x"}}`, "Server.Port", `"x"`},
+		{"path of a list item", `{"Servers": [{"Host": "a"}, {"Port": "x"}]}`, "Servers.1.Port", `"x"`},
+		{"path of a map item", `{"Labels": {"a": "x"}}`, "Labels.a", `"x"`},
+		{"section instead of a value", `{"Ports": {"a": 1}}`, "Ports", "expected a list, got a section"},
+		{"value instead of a section", `{"Database": 1}`, "Database", "expected a section"},
+		{"wrong duration", `{"Timeout": "soon"}`, "Timeout", "soon"},
+		{"fraction to int", `{"Server": {"Port": 1.5}}`, "Server.Port", ""},
+		{"negative to uint", `{"Unsigned": -1}`, "Unsigned", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := unmarshalJson(t, test.document, &testDecoded{})
+
+			fieldErr := &FieldError{}
+
+			if !errors.As(err, &fieldErr) {
+				t.Fatalf("expected a field error, got %v", err)
+			}
+
+			if fieldErr.Path != test.path {
+				t.Errorf("error path %q, expected %q", fieldErr.Path, test.path)
+			}
+
+			if !strings.Contains(err.Error(), test.path+": ") || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("error %q does not contain the path %q and %q", err, test.path, test.message)
+			}
+		})
+	}
+}
+
+func TestDecodeOverflow(t *testing.T) {
+	destination := struct {
+		Small int8   `config:"Small"`
+		Byte  uint8  `config:"Byte"`
+		Int   int64  `config:"Int"`
+		Text  string `config:"Text"`
+	}{}
+
+	err := unmarshalJson(t, `{"Small": 128, "Byte": "256", "Int": 9223372036854775808, "Text": "ok"}`, &destination)
+
+	if err == nil {
+		t.Fatal("overflow is not reported")
+	}
+
+	for _, path := range []string{"Small: ", "Byte: ", "Int: "} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("error %q has no %q", err, path)
+		}
+	}
+
+	if destination.Text != "ok" {
+		t.Errorf("valid fields are not decoded: %+v", destination)
+	}
+}
+
+func TestDecodeHook(t *testing.T) {
+	a := NewConfigAdapter("test")
+
+	if err := a.LoadFromJson([]byte(`{"Host": "db"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	a.AddDecodeHook(func(value any, target reflect.Type) (any, error) {
+		if text, ok := value.(string); ok && target.Kind() == reflect.String {
+			return strings.ToUpper(text), nil
+		}
+
+		return value, nil
+	})
+
+	destination := testServer{}
+
+	if err := a.Unmarshal(&destination, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if destination.Host != "DB" {
+		t.Errorf("hook is not applied: %q", destination.Host)
+	}
+}
+
+func TestDecodeRequired(t *testing.T) {
+	destination := struct {
+		Host string `config:"Host,required"`
+	}{}
+
+	if err := unmarshalJson(t, `{}`, &destination); !errors.Is(err, ErrRequired) {
+		t.Errorf("expected ErrRequired, got %v", err)
+	}
+
+	a := NewConfigAdapter("test")
+
+	if err := a.Unmarshal(&destination, true); err != nil {
+		t.Errorf("required fields are not skipped: %v", err)
+	}
+}
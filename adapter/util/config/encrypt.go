@@ -0,0 +1,313 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Prefix of encrypted values, e.g. "enc:q83vEjRWeJ...".
+const EncryptedValuePrefix = "enc:"
+
+// Environment variables with encryption keys which are used when no
+// cipher is set with SetCipher(). Several keys are separated by
+// commas or line breaks, the first one encrypts values and all of
+// them decrypt values, so keys can be rotated without downtime.
+const (
+	EncryptionKeyEnv     = "CONFIG_ENCRYPTION_KEY"
+	EncryptionKeyFileEnv = "CONFIG_ENCRYPTION_KEY_FILE"
+)
+
+// Size of AES-256 keys.
+const EncryptionKeySize = 32
+
+// Error of an encrypted value which is found when no key is set.
+var ErrNoEncryptionKey = errors.New("encryption key is not set, use " + EncryptionKeyEnv + " or " + EncryptionKeyFileEnv)
+
+// Patterns of a whole encrypted value and of encrypted values in
+// documents. A value in a document starts a line or follows a space,
+// a quote, "=", "," or a bracket, so plain values containing "enc:"
+// are kept. Delimiters after the value are checked separately and
+// not consumed, so values of flow collections are all matched. The
+// loose pattern finds any "enc:" value left after the rotation.
+var (
+	encryptedValuePattern    = regexp.MustCompile(`^` + regexp.QuoteMeta(EncryptedValuePrefix) + `[A-Za-z0-9+/]+=*$`)
+	encryptedDocumentPattern = regexp.MustCompile(`(?m)(?:^|[\s"'=,\[{])(` + regexp.QuoteMeta(EncryptedValuePrefix) + `[A-Za-z0-9+/]+=*)`)
+	encryptedLoosePattern    = regexp.MustCompile(`(?:^|[^A-Za-z0-9_])(` + regexp.QuoteMeta(EncryptedValuePrefix) + `[A-Za-z0-9+/]+=*)`)
+)
+
+// Characters which can follow an encrypted value in a document and
+// characters of URLs and host names which follow "enc:" in plain
+// values.
+const (
+	encryptedValueDelimiters = " \t\r\n\"',;]}#"
+	plainValueCharacters     = "@.:/-_"
+)
+
+// Function checks whether a value is encrypted: it has the "enc:"
+// prefix followed only by base64.
+func isEncryptedValue(value string) bool {
+	return encryptedValuePattern.MatchString(value)
+}
+
+// Structure encrypts configuration values by AES-256-GCM. The nonce
+// is stored with the value so equal values are encrypted differently.
+type ValueCipher struct {
+	aeads []cipher.AEAD
+}
+
+// Function allocates a cipher. The first key encrypts values, all
+// keys are tried to decrypt them.
+func NewValueCipher(keys ...[]byte) (*ValueCipher, error) {
+	if len(keys) == 0 {
+		return nil, ErrNoEncryptionKey
+	}
+
+	c := &ValueCipher{}
+
+	for idx, key := range keys {
+		if len(key) != EncryptionKeySize {
+			return nil, fmt.Errorf("encryption key %d has %d bytes, expected %d", idx, len(key), EncryptionKeySize)
+		}
+
+		block, err := aes.NewCipher(key)
+
+		if err != nil {
+			return nil, err
+		}
+
+		aead, err := cipher.NewGCM(block)
+
+		if err != nil {
+			return nil, err
+		}
+
+		c.aeads = append(c.aeads, aead)
+	}
+
+	return c, nil
+}
+
+// Function allocates a cipher with keys of the environment
+// variables. ErrNoEncryptionKey is returned if they are not set.
+func NewValueCipherFromEnv() (*ValueCipher, error) {
+	if text, ok := os.LookupEnv(EncryptionKeyEnv); ok && text != "" {
+		return NewValueCipherFromString(text)
+	}
+
+	if fileName, ok := os.LookupEnv(EncryptionKeyFileEnv); ok && fileName != "" {
+		return NewValueCipherFromFile(fileName)
+	}
+
+	return nil, ErrNoEncryptionKey
+}
+
+// Function allocates a cipher with keys of a file. Keys are
+// separated by line breaks.
+func NewValueCipherFromFile(fileName string) (*ValueCipher, error) {
+	data, err := os.ReadFile(fileName)
+
+	if err != nil {
+		return nil, fmt.Errorf("encryption key file cannot be read: %w", err)
+	}
+
+	return NewValueCipherFromString(string(data))
+}
+
+// Function allocates a cipher with base64 or hex encoded keys
+// separated by commas, spaces or line breaks.
+func NewValueCipherFromString(text string) (*ValueCipher, error) {
+	keys := [][]byte{}
+
+	for _, encoded := range strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\r' || r == '\t' }) {
+		key, err := DecodeEncryptionKey(encoded)
+
+		if err != nil {
+			return nil, err
+		}
+
+		keys = append(keys, key)
+	}
+
+	return NewValueCipher(keys...)
+}
+
+// Function generates a random key.
+func GenerateEncryptionKey() ([]byte, error) {
+	key := make([]byte, EncryptionKeySize)
+
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// Function encodes a key to store it in a file or a variable.
+func EncodeEncryptionKey(key []byte) string {
+	return base64.StdEncoding.EncodeToString(key)
+}
+
+// Function decodes a base64 or hex encoded key.
+func DecodeEncryptionKey(encoded string) ([]byte, error) {
+	if key, err := hex.DecodeString(encoded); err == nil && len(key) == EncryptionKeySize {
+		return key, nil
+	}
+
+	key, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return nil, errors.New("encryption key must be encoded by base64 or hex")
+	}
+
+	return key, nil
+}
+
+// Function encrypts a value by the first key and returns it with
+// the "enc:" prefix.
+func (c *ValueCipher) Encrypt(plain string) (string, error) {
+	aead := c.aeads[0]
+	nonce := make([]byte, aead.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := aead.Seal(nonce, nonce, []byte(plain), nil)
+
+	return EncryptedValuePrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Function decrypts a value with the "enc:" prefix. Every key is
+// tried, a value changed after encryption is not accepted.
+func (c *ValueCipher) Decrypt(value string) (string, error) {
+	encoded, ok := strings.CutPrefix(value, EncryptedValuePrefix)
+
+	if !ok {
+		return "", fmt.Errorf("value has no %s prefix", EncryptedValuePrefix)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+
+	if err != nil {
+		return "", errors.New("encrypted value is not encoded by base64")
+	}
+
+	for _, aead := range c.aeads {
+		if len(sealed) < aead.NonceSize() {
+			return "", errors.New("encrypted value is too short")
+		}
+
+		plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], nil)
+
+		if err == nil {
+			return string(plain), nil
+		}
+	}
+
+	return "", errors.New("encrypted value cannot be decrypted by the keys")
+}
+
+// Function encrypts again every encrypted value of a document by
+// the first key of the target cipher. Other text is kept, so files
+// of every format can be rotated. The result is checked: every
+// "enc:" value left in it must be decrypted by the target cipher.
+func RotateEncryptedValues(data []byte, from *ValueCipher, to *ValueCipher) ([]byte, error) {
+	text := string(data)
+	result := strings.Builder{}
+	last := 0
+	errs := []error{}
+
+	for _, match := range encryptedDocumentPattern.FindAllStringSubmatchIndex(text, -1) {
+		start, end := match[2], match[3]
+
+		if end < len(text) && !strings.ContainsRune(encryptedValueDelimiters, rune(text[end])) {
+			continue
+		}
+
+		plain, err := from.Decrypt(text[start:end])
+		value := text[start:end]
+
+		if err == nil {
+			value, err = to.Encrypt(plain)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("value at offset %d: %w", start, err))
+		}
+
+		result.WriteString(text[last:start])
+		result.WriteString(value)
+
+		last = end
+	}
+
+	result.WriteString(text[last:])
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	rotated := result.String()
+
+	for _, match := range encryptedLoosePattern.FindAllStringSubmatchIndex(rotated, -1) {
+		if match[3] < len(rotated) && strings.ContainsRune(plainValueCharacters, rune(rotated[match[3]])) {
+			continue
+		}
+
+		if _, err := to.Decrypt(rotated[match[2]:match[3]]); err != nil {
+			errs = append(errs, fmt.Errorf("value at offset %d is not rotated: %w", match[2], err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	return []byte(rotated), nil
+}
+
+// SetCipher sets the cipher of encrypted values in the root adapter.
+// Loaded values are resolved again. Without a cipher the keys of
+// the environment variables are used.
+func (a *ConfigAdapter) SetCipher(c *ValueCipher) error {
+	root := a.getRoot()
+
+	root.mutex.Lock()
+	defer root.mutex.Unlock()
+
+	root.cipher = c
+
+	config, err := root.buildConfig(root.sources)
+
+	if err != nil {
+		return err
+	}
+
+	root.config = config
+
+	return nil
+}
+
+// Function decrypts a value with the "enc:" prefix. The cipher of
+// the environment is loaded once. The mutex must be locked.
+func (a *ConfigAdapter) decryptValue(value string) (string, error) {
+	if a.cipher == nil {
+		c, err := NewValueCipherFromEnv()
+
+		if err != nil {
+			return "", err
+		}
+
+		a.cipher = c
+	}
+
+	return a.cipher.Decrypt(value)
+}
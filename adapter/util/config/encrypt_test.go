@@ -0,0 +1,109 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func newTestCipher(t *testing.T) *ValueCipher {
+	t.Helper()
+
+	key, err := GenerateEncryptionKey()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewValueCipher(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestRotateEncryptedValues(t *testing.T) {
+	from, to := newTestCipher(t), newTestCipher(t)
+
+	first, _ := from.Encrypt("first")
+	second, _ := from.Encrypt("second")
+
+	tests := []struct {
+		name     string
+		document string
+		plain    []string
+	}{
+		{"yaml value", "key: " + first + "\n", []string{"first"}},
+		{"yaml flow sequence", "key: [" + first + ", " + second + "]\n", []string{"first", "second"}},
+		{"yaml flow sequence without spaces", "key: [" + first + "," + second + "]\n", []string{"first", "second"}},
+		{"yaml flow mapping", "key: {a: " + first + ",b: " + second + "}\n", []string{"first", "second"}},
+		{"json", `{"a":"` + first + `","b":["` + second + `"]}`, []string{"first", "second"}},
+		{"env", "A=" + first + "\nB=" + second, []string{"first", "second"}},
+		{"plain values", "a: xenc:QUJD\nb: http://host/enc:x@y\n", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rotated, err := RotateEncryptedValues([]byte(test.document), from, to)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if bytes.Contains(rotated, []byte(first)) || bytes.Contains(rotated, []byte(second)) {
+				t.Fatalf("values are not rotated: %s", rotated)
+			}
+
+			matches := encryptedDocumentPattern.FindAllStringSubmatch(string(rotated), -1)
+
+			if len(matches) != len(test.plain) {
+				t.Fatalf("%d values are found, expected %d: %s", len(matches), len(test.plain), rotated)
+			}
+
+			for idx, match := range matches {
+				plain, err := to.Decrypt(match[1])
+
+				if err != nil || plain != test.plain[idx] {
+					t.Errorf("value %d is decrypted to %q (%v), expected %q", idx, plain, err, test.plain[idx])
+				}
+			}
+
+			if test.plain == nil && string(rotated) != test.document {
+				t.Errorf("plain document is changed: %s", rotated)
+			}
+		})
+	}
+}
+
+func TestRotateEncryptedValuesUnrotated(t *testing.T) {
+	from, to := newTestCipher(t), newTestCipher(t)
+
+	value, _ := from.Encrypt("value")
+
+	// the value is followed by a character which is not a delimiter
+	_, err := RotateEncryptedValues([]byte("key: ("+value+")\n"), from, to)
+
+	if err == nil || !strings.Contains(err.Error(), "is not rotated") {
+		t.Fatalf("unrotated value is not reported: %v", err)
+	}
+}
+
+func TestIsEncryptedValue(t *testing.T) {
+	tests := map[string]bool{
+		"enc:QUJD":       true,
+		"enc:QUJD==":     true,
+		"enc:":           false,
+		"xenc:QUJD":      false,
+		"enc:QU JD":      false,
+		"value enc:QUJD": false,
+		"plain":          false,
+	}
+
+	for value, expected := range tests {
+		if isEncryptedValue(value) != expected {
+			t.Errorf("isEncryptedValue(%q) != %v", value, expected)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported configuration file formats.
+const (
+	FormatJson = "json"
+	FormatYaml = "yaml"
+	FormatToml = "toml"
+)
+
+var formatExtensions = map[string]string{
+	".json":  FormatJson,
+	".jsonc": FormatJson,
+	".json5": FormatJson,
+	".yaml":  FormatYaml,
+	".yml":   FormatYaml,
+	".toml":  FormatToml,
+}
+
+// Function returns the format of a configuration file by its
+// extension.
+func FormatFromPath(filePath string) (string, error) {
+	format, ok := formatExtensions[strings.ToLower(filepath.Ext(filePath))]
+
+	if !ok {
+		return "", fmt.Errorf("unsupported configuration file format %s, use .json, .jsonc, .yaml, .yml or .toml", filePath)
+	}
+
+	return format, nil
+}
+
+// Function parses a document into the configuration tree. All
+// formats produce the same types: maps with string keys, []any
+// slices, strings, booleans and numbers. Integers are int64 (uint64
+// above the int64 range) so they keep their precision, other numbers
+// are float64. JSON allows comments and trailing commas.
+func parseConfig(format string, data []byte) (map[string]any, error) {
+	cnf := make(map[string]any)
+
+	switch format {
+	case FormatJson:
+		if err := decodeJson(stripJsonc(data), &cnf); err != nil {
+			return nil, err
+		}
+	case FormatYaml:
+		if err := yaml.Unmarshal(data, &cnf); err != nil {
+			return nil, err
+		}
+	case FormatToml:
+		if err := toml.Unmarshal(data, &cnf); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported configuration format %s", format)
+	}
+
+	return normalizeValue(cnf).(map[string]any), nil
+}
+
+// Function decodes JSON keeping integers as json.Number, they are
+// converted by normalizeValue().
+func decodeJson(data []byte, value any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	if err := decoder.Decode(value); err != nil {
+		return err
+	}
+
+	if decoder.More() {
+		return fmt.Errorf("unexpected data after the JSON value at offset %d", decoder.InputOffset())
+	}
+
+	return nil
+}
+
+// Function converts values decoded from JSON, YAML or TOML to the
+// types of the configuration tree.
+func normalizeValue(value any) any {
+	switch v := value.(type) {
+	case nil:
+		return map[string]any{}
+	case map[string]any:
+		result := make(map[string]any, len(v))
+
+		for key, item := range v {
+			result[key] = normalizeItem(item)
+		}
+
+		return result
+	case map[any]any:
+		result := make(map[string]any, len(v))
+
+		for key, item := range v {
+			result[fmt.Sprint(key)] = normalizeItem(item)
+		}
+
+		return result
+	}
+
+	return value
+}
+
+func normalizeItem(value any) any {
+	switch v := value.(type) {
+	case map[string]any, map[any]any:
+		return normalizeValue(v)
+	case []map[string]any:
+		result := make([]any, len(v))
+
+		for idx, item := range v {
+			result[idx] = normalizeValue(item)
+		}
+
+		return result
+	case []any:
+		result := make([]any, len(v))
+
+		for idx, item := range v {
+			result[idx] = normalizeItem(item)
+		}
+
+		return result
+	case json.Number:
+		if number, err := v.Int64(); err == nil {
+			return number
+		}
+
+		if number, err := strconv.ParseUint(v.String(), 10, 64); err == nil {
+			return number
+		}
+
+		number, _ := v.Float64()
+
+		return number
+	case int:
+		return int64(v)
+	case uint64:
+		if v <= math.MaxInt64 {
+			return int64(v)
+		}
+	case float32:
+		return float64(v)
+	case time.Time:
+		// YAML timestamps and TOML dates
+		return v.Format(time.RFC3339Nano)
+	}
+
+	return value
+}
+
+// Function removes // and /* */ comments and trailing commas before
+// closing brackets outside of strings. Line breaks are kept so
+// parser errors point to the right line.
+func stripJsonc(data []byte) []byte {
+	result := make([]byte, 0, len(data))
+
+	for idx := 0; idx < len(data); idx++ {
+		c := data[idx]
+
+		switch {
+		case c == '"':
+			end := skipString(data, idx)
+			result = append(result, data[idx:end]...)
+			idx = end - 1
+		case c == '/' && idx+1 < len(data) && (data[idx+1] == '/' || data[idx+1] == '*'):
+			end := skipComment(data, idx)
+			result = append(result, blank(data[idx:end])...)
+			idx = end - 1
+		case c == ',' && isTrailingComma(data, idx+1):
+			result = append(result, ' ')
+		default:
+			result = append(result, c)
+		}
+	}
+
+	return result
+}
+
+// Function returns the index after the string which starts at
+// the position.
+func skipString(data []byte, start int) int {
+	for idx := start + 1; idx < len(data); idx++ {
+		switch data[idx] {
+		case '\\':
+			idx++
+		case '"':
+			return idx + 1
+		}
+	}
+
+	return len(data)
+}
+
+// Function returns the index after the comment which starts at
+// the position.
+func skipComment(data []byte, start int) int {
+	if data[start+1] == '/' {
+		for idx := start + 2; idx < len(data); idx++ {
+			if data[idx] == '\n' {
+				return idx
+			}
+		}
+
+		return len(data)
+	}
+
+	for idx := start + 2; idx+1 < len(data); idx++ {
+		if data[idx] == '*' && data[idx+1] == '/' {
+			return idx + 2
+		}
+	}
+
+	return len(data)
+}
+
+// Function checks whether the next significant character is a
+// closing bracket.
+func isTrailingComma(data []byte, start int) bool {
+	for idx := start; idx < len(data); idx++ {
+		switch c := data[idx]; {
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+		case c == '/' && idx+1 < len(data) && (data[idx+1] == '/' || data[idx+1] == '*'):
+			idx = skipComment(data, idx) - 1
+		default:
+			return c == '}' || c == ']'
+		}
+	}
+
+	return false
+}
+
+// Function replaces a comment with spaces keeping line breaks.
+func blank(comment []byte) []byte {
+	result := make([]byte, len(comment))
+
+	for idx, c := range comment {
+		if c == '\n' {
+			result[idx] = c
+		} else {
+			result[idx] = ' '
+		}
+	}
+
+	return result
+}
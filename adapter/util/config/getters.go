@@ -0,0 +1,143 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// Get decodes the value of the path into the type like Unmarshal()
+// does for fields. Strings set by environment variables and
+// arguments are converted, e.g. "8080" to int or "a,b" to []string.
+func Get[T any](a *ConfigAdapter, path ...string) (result T, err error) {
+	value, err := a.GetValue(path...)
+
+	if err != nil {
+		return result, err
+	}
+
+	a.mutex.RLock()
+	value = cloneValue(value)
+	a.mutex.RUnlock()
+
+	d := &decoder{hooks: a.getDecodeHooks(), logger: a.Logger}
+	d.decode(append(slices.Clone(a.prefix), path...), value, reflect.ValueOf(&result).Elem())
+
+	return result, errors.Join(d.errs...)
+}
+
+// GetOrDefault returns the default value if the path is not found or
+// the value cannot be converted to the type.
+func GetOrDefault[T any](a *ConfigAdapter, defaultValue T, path ...string) T {
+	result, err := Get[T](a, path...)
+
+	if err != nil {
+		return defaultValue
+	}
+
+	return result
+}
+
+func (a *ConfigAdapter) GetInt(path ...string) (int, error) {
+	return Get[int](a, path...)
+}
+
+func (a *ConfigAdapter) GetIntOrDefault(defaultValue int, path ...string) int {
+	return GetOrDefault(a, defaultValue, path...)
+}
+
+func (a *ConfigAdapter) GetInt64(path ...string) (int64, error) {
+	return Get[int64](a, path...)
+}
+
+func (a *ConfigAdapter) GetInt64OrDefault(defaultValue int64, path ...string) int64 {
+	return GetOrDefault(a, defaultValue, path...)
+}
+
+func (a *ConfigAdapter) GetFloat(path ...string) (float64, error) {
+	return Get[float64](a, path...)
+}
+
+func (a *ConfigAdapter) GetFloatOrDefault(defaultValue float64, path ...string) float64 {
+	return GetOrDefault(a, defaultValue, path...)
+}
+
+// Function returns a boolean value. Strings are parsed by
+// strconv.ParseBool(), e.g. "true", "1" or "f".
+func (a *ConfigAdapter) GetBool(path ...string) (bool, error) {
+	return Get[bool](a, path...)
+}
+
+func (a *ConfigAdapter) GetBoolOrDefault(defaultValue bool, path ...string) bool {
+	return GetOrDefault(a, defaultValue, path...)
+}
+
+// Function returns a duration set by a string like "1m30s" or by a
+// number of seconds.
+func (a *ConfigAdapter) GetDuration(path ...string) (time.Duration, error) {
+	return Get[time.Duration](a, path...)
+}
+
+func (a *ConfigAdapter) GetDurationOrDefault(defaultValue time.Duration, path ...string) time.Duration {
+	return GetOrDefault(a, defaultValue, path...)
+}
+
+// Function returns a list of strings. A string value is split by
+// commas.
+func (a *ConfigAdapter) GetStringSlice(path ...string) ([]string, error) {
+	return Get[[]string](a, path...)
+}
+
+func (a *ConfigAdapter) GetStringSliceOrDefault(defaultValue []string, path ...string) []string {
+	return GetOrDefault(a, defaultValue, path...)
+}
+
+// Function returns a section as a map of strings.
+func (a *ConfigAdapter) GetStringMap(path ...string) (map[string]string, error) {
+	return Get[map[string]string](a, path...)
+}
+
+// Function returns sorted keys of a section or indexes of a list.
+// Use it to iterate sections with names defined by the
+// configuration.
+func (a *ConfigAdapter) Keys(path ...string) ([]string, error) {
+	value, err := a.GetValue(path...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	switch v := value.(type) {
+	case map[string]any:
+		keys := maps.Keys(v)
+		slices.Sort(keys)
+
+		return keys, nil
+	case []any:
+		keys := make([]string, len(v))
+
+		for idx := range v {
+			keys[idx] = strconv.Itoa(idx)
+		}
+
+		return keys, nil
+	}
+
+	return nil, fmt.Errorf("value of the path %s is not a section or a list", strings.Join(path, "."))
+}
+
+// Function checks whether the path exists.
+func (a *ConfigAdapter) Has(path ...string) bool {
+	_, err := a.GetValue(path...)
+
+	return err == nil
+}
@@ -0,0 +1,191 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// Structure describes a changed value. A missing value is nil.
+type ConfigChange struct {
+	Path     []string
+	OldValue any
+	NewValue any
+}
+
+// Function returns the path of the change joined with dots.
+func (c ConfigChange) String() string {
+	return strings.Join(c.Path, ".")
+}
+
+// Function is called after a reload with changes of the subscribed
+// path. The section is the new configuration of the path or nil if
+// the path is removed or is not a section.
+type ConfigSubscriberFunc func(section *ConfigAdapter, changes []ConfigChange)
+
+type subscription struct {
+	path     []string
+	callback ConfigSubscriberFunc
+}
+
+func (a *ConfigAdapter) getRoot() *ConfigAdapter {
+	if a.root != nil {
+		return a.root
+	}
+
+	return a
+}
+
+// Function returns the configuration files loaded by the adapter.
+// Use it to watch files for changes.
+func (a *ConfigAdapter) GetFiles() []string {
+	root := a.getRoot()
+
+	root.mutex.RLock()
+	defer root.mutex.RUnlock()
+
+	return slices.Clone(root.files)
+}
+
+// Function registers a callback called when values under the path
+// are changed by Reload(). The path of a section returned by
+// GetAdapter() is relative to the section. Use the returned
+// function to unsubscribe.
+func (a *ConfigAdapter) Subscribe(callback ConfigSubscriberFunc, path ...string) func() {
+	root := a.getRoot()
+	sub := &subscription{path: append(slices.Clone(a.prefix), path...), callback: callback}
+
+	root.subMutex.Lock()
+	defer root.subMutex.Unlock()
+
+	root.subscribers = append(root.subscribers, sub)
+
+	return func() {
+		root.subMutex.Lock()
+		defer root.subMutex.Unlock()
+
+		if idx := slices.Index(root.subscribers, sub); idx >= 0 {
+			root.subscribers = slices.Delete(root.subscribers, idx, idx+1)
+		}
+	}
+}
+
+// Function loads all sources again, replaces the configuration and
+// notifies subscribers about changed paths. If any source fails the
+// current configuration is kept. Sections returned by GetAdapter()
+// are snapshots and are not updated.
+func (a *ConfigAdapter) Reload() ([]ConfigChange, error) {
+	root := a.getRoot()
+
+	root.mutex.RLock()
+	sources := slices.Clone(root.sources)
+	root.mutex.RUnlock()
+
+	fresh := []*configSource{}
+
+	for _, src := range sources {
+		if src.load == nil {
+			fresh = append(fresh, src)
+
+			continue
+		}
+
+		values, err := src.load()
+
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", src.name, err)
+		}
+
+		fresh = append(fresh, &configSource{name: src.name, layer: src.layer, load: src.load, values: values, foldKeys: src.foldKeys})
+	}
+
+	root.mutex.Lock()
+
+	// keep values set and sources added while sources were loading
+	for _, src := range root.sources {
+		idx := slices.IndexFunc(fresh, func(s *configSource) bool { return s.name == src.name })
+
+		if idx < 0 {
+			fresh = append(fresh, src)
+		} else if src.load == nil {
+			fresh[idx] = src
+		}
+	}
+
+	config, err := root.buildConfig(fresh)
+
+	if err != nil {
+		root.mutex.Unlock()
+
+		return nil, err
+	}
+
+	changes := diffValues(nil, root.config, config)
+	root.sources = fresh
+	root.config = config
+	root.mutex.Unlock()
+
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	root.subMutex.Lock()
+	subscribers := slices.Clone(root.subscribers)
+	root.subMutex.Unlock()
+
+	for _, sub := range subscribers {
+		related := []ConfigChange{}
+
+		for _, change := range changes {
+			if hasPrefix(change.Path, sub.path) || hasPrefix(sub.path, change.Path) {
+				related = append(related, change)
+			}
+		}
+
+		if len(related) > 0 {
+			sub.callback(root.GetAdapterOrNil(sub.path...), related)
+		}
+	}
+
+	return changes, nil
+}
+
+// Function compares two values recursively and returns changes
+// of leaf values sorted by path.
+func diffValues(path []string, oldValue any, newValue any) []ConfigChange {
+	oldMap, oldOk := oldValue.(map[string]any)
+	newMap, newOk := newValue.(map[string]any)
+
+	if !oldOk || !newOk {
+		if reflect.DeepEqual(oldValue, newValue) {
+			return nil
+		}
+
+		return []ConfigChange{{Path: path, OldValue: oldValue, NewValue: newValue}}
+	}
+
+	keys := maps.Keys(oldMap)
+
+	for key := range newMap {
+		if _, ok := oldMap[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	slices.Sort(keys)
+
+	changes := []ConfigChange{}
+
+	for _, key := range keys {
+		changes = append(changes, diffValues(append(slices.Clone(path), key), oldMap[key], newMap[key])...)
+	}
+
+	return changes
+}
+
+func hasPrefix(path []string, prefix []string) bool {
+	return len(path) >= len(prefix) && slices.Equal(path[:len(prefix)], prefix)
+}
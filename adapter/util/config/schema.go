@@ -0,0 +1,236 @@
+package config
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Version of the JSON Schema produced by JsonSchema().
+const JsonSchemaVersion = "https://json-schema.org/draft/2020-12/schema"
+
+// Function builds a JSON Schema of the configuration section decoded
+// into the structure by Unmarshal(). Names, nesting, required fields,
+// defaults and validation rules are taken from the "config" tags.
+// Other keys of the section are allowed.
+func JsonSchema(configStruct any) map[string]any {
+	t := reflect.TypeOf(configStruct)
+
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return map[string]any{"type": "object"}
+	}
+
+	return structSchema(t)
+}
+
+func structSchema(t reflect.Type) map[string]any {
+	schema := map[string]any{"type": "object", "properties": map[string]any{}}
+
+	addStructFields(schema, t)
+
+	return schema
+}
+
+// Function adds properties of tagged fields to an object schema.
+// Fields of embedded structures without the tag are added to the
+// same object.
+func addStructFields(schema map[string]any, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldTagString, ok := field.Tag.Lookup(TagConfigName)
+
+		if field.Anonymous && fieldTagString == "" {
+			embedded := field.Type
+
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+
+			if embedded.Kind() == reflect.Struct {
+				addStructFields(schema, embedded)
+			}
+
+			continue
+		}
+
+		if !ok || fieldTagString == "" {
+			continue
+		}
+
+		tag, err := parseTag(fieldTagString)
+
+		if err != nil {
+			continue
+		}
+
+		// dotted names are nested objects
+		parent := schema
+
+		for _, key := range tag.keys[:len(tag.keys)-1] {
+			properties := parent["properties"].(map[string]any)
+
+			if _, ok := properties[key]; !ok {
+				properties[key] = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+
+			parent = properties[key].(map[string]any)
+		}
+
+		name := tag.keys[len(tag.keys)-1]
+
+		parent["properties"].(map[string]any)[name] = fieldSchema(field.Type, tag)
+
+		if tag.required {
+			required, _ := parent["required"].([]string)
+
+			if !slices.Contains(required, name) {
+				parent["required"] = append(required, name)
+			}
+		}
+	}
+}
+
+func fieldSchema(t reflect.Type, tag fieldTag) map[string]any {
+	schema := typeSchema(t)
+
+	if tag.defaultValue != nil {
+		schema["default"] = defaultSchemaValue(t, *tag.defaultValue)
+	}
+
+	for _, rule := range tag.rules {
+		addRule(schema, t, rule)
+	}
+
+	return schema
+}
+
+func typeSchema(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == durationType {
+		return map[string]any{"type": []string{"string", "number"}, "description": "duration like \"1m30s\" or a number of seconds"}
+	}
+
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]any{"type": "string"}
+		}
+
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		return structSchema(t)
+	}
+
+	return map[string]any{}
+}
+
+// Function converts a default value of the tag to the JSON type of
+// the field.
+func defaultSchemaValue(t reflect.Type, value string) any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == durationType {
+		return value
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(value); err == nil {
+			return b
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if f, err := strconv.ParseFloat(value, 64); err == nil {
+			return f
+		}
+	case reflect.Slice, reflect.Array:
+		items, _ := toList(value)
+
+		return items
+	}
+
+	return value
+}
+
+func addRule(schema map[string]any, t reflect.Type, rule fieldRule) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// rules for strings are applied to items of lists
+	target := schema
+
+	if items, ok := schema["items"].(map[string]any); ok && rule.name != TagConfigMinName && rule.name != TagConfigMaxName && rule.name != TagConfigNonEmptyName {
+		target = items
+	}
+
+	switch rule.name {
+	case TagConfigMinName, TagConfigMaxName:
+		limit, err := strconv.ParseFloat(rule.arg, 64)
+
+		if err != nil || t == durationType {
+			return
+		}
+
+		keywords := [2]string{"minimum", "maximum"}
+
+		switch t.Kind() {
+		case reflect.String:
+			keywords = [2]string{"minLength", "maxLength"}
+		case reflect.Slice, reflect.Array:
+			keywords = [2]string{"minItems", "maxItems"}
+		case reflect.Map:
+			keywords = [2]string{"minProperties", "maxProperties"}
+		}
+
+		if rule.name == TagConfigMinName {
+			schema[keywords[0]] = limit
+		} else {
+			schema[keywords[1]] = limit
+		}
+	case TagConfigNonEmptyName:
+		switch t.Kind() {
+		case reflect.String:
+			schema["minLength"] = 1
+		case reflect.Slice, reflect.Array:
+			schema["minItems"] = 1
+		case reflect.Map:
+			schema["minProperties"] = 1
+		}
+	case TagConfigOneOfName:
+		target["enum"] = strings.Split(rule.arg, "|")
+	case TagConfigUrlName:
+		target["format"] = "uri"
+	case TagConfigHostPortName:
+		target["pattern"] = `^.*:[0-9]{1,5}$`
+	case TagConfigRegexName:
+		target["pattern"] = rule.arg
+	}
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+)
+
+// Scheme of references to files, e.g. "file:///run/secrets/db".
+const SecretSchemeFile = "file"
+
+// Interface of a secret storage. A string value "scheme://path" is
+// replaced by the secret returned by the provider registered for
+// the scheme. Implement it to read secrets from Vault-like backends.
+type SecretProvider interface {
+	GetSecret(path string) (string, error)
+}
+
+// Provider reads secrets from files. If the directory is set, paths
+// are file names inside it (e.g. Kubernetes or Docker secret mounts),
+// otherwise paths are absolute file names. Trailing line breaks are
+// removed.
+type FileSecretProvider struct {
+	Dir string
+}
+
+// Function allocates a provider reading files from the directory.
+// An empty directory allows absolute file names.
+func NewFileSecretProvider(dir string) *FileSecretProvider {
+	return &FileSecretProvider{Dir: dir}
+}
+
+func (p *FileSecretProvider) GetSecret(path string) (string, error) {
+	fileName := path
+
+	if p.Dir != "" {
+		fileName = filepath.Join(p.Dir, filepath.Clean("/"+path))
+	}
+
+	data, err := os.ReadFile(fileName)
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// Pattern of environment references: ${NAME} or ${NAME:-default}.
+// References prefixed with one more $ are kept as is without it.
+var envReferencePattern = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// AddSecretProvider registers a provider for references with the
+// scheme in the root adapter. Loaded values are resolved again so
+// the provider can be registered after sources. If they cannot be
+// resolved an error is returned and the configuration is kept.
+func (a *ConfigAdapter) AddSecretProvider(scheme string, provider SecretProvider) error {
+	root := a.getRoot()
+
+	root.mutex.Lock()
+	defer root.mutex.Unlock()
+
+	if root.secretProviders == nil {
+		root.secretProviders = make(map[string]SecretProvider)
+	}
+
+	root.secretProviders[scheme] = provider
+
+	config, err := root.buildConfig(root.sources)
+
+	if err != nil {
+		return err
+	}
+
+	root.config = config
+
+	return nil
+}
+
+// Function returns the provider of the scheme. The file provider is
+// available by default. The mutex must be locked.
+func (a *ConfigAdapter) secretProvider(scheme string) (SecretProvider, bool) {
+	if provider, ok := a.secretProviders[scheme]; ok {
+		return provider, true
+	}
+
+	if scheme == SecretSchemeFile {
+		return &FileSecretProvider{}, true
+	}
+
+	return nil, false
+}
+
+// Function replaces environment and secret references in strings
+// of the value. Maps and slices are changed in place. Errors of all
+// values are returned together. The mutex must be locked.
+func (a *ConfigAdapter) resolveValue(path []string, value any) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		errs := []error{}
+		keys := maps.Keys(v)
+
+		slices.Sort(keys)
+
+		for _, key := range keys {
+			item, err := a.resolveValue(append(slices.Clone(path), key), v[key])
+
+			if err != nil {
+				errs = append(errs, err)
+
+				continue
+			}
+
+			v[key] = item
+		}
+
+		return v, errors.Join(errs...)
+	case []any:
+		errs := []error{}
+
+		for idx, item := range v {
+			item, err := a.resolveValue(append(slices.Clone(path), strconv.Itoa(idx)), item)
+
+			if err != nil {
+				errs = append(errs, err)
+
+				continue
+			}
+
+			v[idx] = item
+		}
+
+		return v, errors.Join(errs...)
+	case string:
+		resolved, err := a.resolveString(v)
+
+		if err != nil {
+			return nil, &FieldError{Path: strings.Join(path, "."), Err: err}
+		}
+
+		return resolved, nil
+	}
+
+	return value, nil
+}
+
+// Function substitutes environment references and then decrypts an
+// "enc:" value or replaces a "scheme://path" value by the secret if
+// the scheme is registered.
+func (a *ConfigAdapter) resolveString(value string) (string, error) {
+	errs := []error{}
+
+	value = envReferencePattern.ReplaceAllStringFunc(value, func(reference string) string {
+		if strings.HasPrefix(reference, "$$") {
+			return reference[1:]
+		}
+
+		match := envReferencePattern.FindStringSubmatch(reference)
+
+		if env, ok := os.LookupEnv(match[1]); ok && (env != "" || match[2] == "") {
+			return env
+		}
+
+		if match[2] != "" {
+			return match[3]
+		}
+
+		errs = append(errs, fmt.Errorf("environment variable %s is not set", match[1]))
+
+		return reference
+	})
+
+	if len(errs) > 0 {
+		return "", errors.Join(errs...)
+	}
+
+	if isEncryptedValue(value) {
+		return a.decryptValue(value)
+	}
+
+	scheme, path, ok := strings.Cut(value, "://")
+
+	if !ok {
+		return value, nil
+	}
+
+	provider, ok := a.secretProvider(scheme)
+
+	if !ok {
+		return value, nil
+	}
+
+	secret, err := provider.GetSecret(path)
+
+	if err != nil {
+		return "", fmt.Errorf("secret %s cannot be read: %w", value, err)
+	}
+
+	return secret, nil
+}
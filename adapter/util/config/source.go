@@ -0,0 +1,414 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/exp/slices"
+)
+
+// Precedence of a configuration source. Values of a higher layer
+// override values of lower ones, sources of the same layer override
+// each other in the order of loading.
+type ConfigLayer int
+
+const (
+	LayerDefaults ConfigLayer = iota
+	LayerFile
+	LayerOverlay
+	LayerEnv
+	LayerArgs
+	LayerRuntime
+)
+
+var layerNames = map[ConfigLayer]string{
+	LayerDefaults: "defaults",
+	LayerFile:     "file",
+	LayerOverlay:  "overlay",
+	LayerEnv:      "env",
+	LayerArgs:     "args",
+	LayerRuntime:  "runtime",
+}
+
+// Function returns the name of the layer.
+func (l ConfigLayer) String() string {
+	if name, ok := layerNames[l]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("layer(%d)", int(l))
+}
+
+// Function loads values of a configuration source. It is called
+// when the source is added and by every Reload().
+type ConfigLoaderFunc func() (map[string]any, error)
+
+// Name of the source holding values set by SetValue().
+const runtimeSourceName = "runtime"
+
+// Name of the source holding values set by SetDefaults().
+const defaultsSourceName = "defaults"
+
+type configSource struct {
+	name   string
+	layer  ConfigLayer
+	load   ConfigLoaderFunc
+	values map[string]any
+
+	// keys are matched with keys of lower sources ignoring the case
+	foldKeys bool
+}
+
+// AddSource loads a named source and merges it into the
+// configuration according to its layer. A source with the same
+// name is replaced. If the loader fails the source is not added.
+func (a *ConfigAdapter) AddSource(name string, layer ConfigLayer, load ConfigLoaderFunc) error {
+	return a.addSource(name, layer, load, false)
+}
+
+func (a *ConfigAdapter) addSource(name string, layer ConfigLayer, load ConfigLoaderFunc, foldKeys bool) error {
+	values, err := load()
+
+	if err != nil {
+		return err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	return a.putSource(&configSource{name: name, layer: layer, load: load, values: values, foldKeys: foldKeys})
+}
+
+// SetDefaults merges values into the lowest layer. Every other
+// source overrides them.
+func (a *ConfigAdapter) SetDefaults(values map[string]any) error {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	defaults := map[string]any{}
+
+	if idx := a.sourceIndex(defaultsSourceName); idx >= 0 {
+		defaults = cloneValue(a.sources[idx].values).(map[string]any)
+	}
+
+	mergeMaps(defaults, values)
+
+	return a.putSource(&configSource{name: defaultsSourceName, layer: LayerDefaults, values: defaults})
+}
+
+// GetSources returns names of the sources from the lowest
+// precedence to the highest.
+func (a *ConfigAdapter) GetSources() []string {
+	root := a.getRoot()
+
+	root.mutex.RLock()
+	defer root.mutex.RUnlock()
+
+	names := []string{}
+
+	for _, src := range sortSources(root.sources) {
+		names = append(names, src.name)
+	}
+
+	return names
+}
+
+// GetValueSource returns the name of the source which supplied the
+// value of the path. For a section it is the source with the highest
+// precedence which has any value of the section. The path of a
+// section returned by GetAdapter() is relative to the section.
+func (a *ConfigAdapter) GetValueSource(path ...string) (string, error) {
+	root := a.getRoot()
+	fullPath := append(slices.Clone(a.prefix), path...)
+
+	root.mutex.RLock()
+	defer root.mutex.RUnlock()
+
+	sources := sortSources(root.sources)
+
+	for idx := len(sources) - 1; idx >= 0; idx-- {
+		if _, ok := sources[idx].lookup(fullPath); ok {
+			return sources[idx].name, nil
+		}
+	}
+
+	return "", errors.New("value not found for path " + strings.Join(fullPath, "."))
+}
+
+// Function replaces or appends a source and rebuilds the
+// configuration. If references cannot be resolved the source is
+// not added. The mutex must be locked.
+func (a *ConfigAdapter) putSource(src *configSource) error {
+	sources := slices.Clone(a.sources)
+
+	if idx := a.sourceIndex(src.name); idx >= 0 {
+		sources[idx] = src
+	} else {
+		sources = append(sources, src)
+	}
+
+	config, err := a.buildConfig(sources)
+
+	if err != nil {
+		return err
+	}
+
+	a.sources = sources
+	a.config = config
+
+	return nil
+}
+
+// Function merges sources and resolves references. The mutex must
+// be locked.
+func (a *ConfigAdapter) buildConfig(sources []*configSource) (map[string]any, error) {
+	config := mergeSources(sources)
+
+	if _, err := a.resolveValue(nil, config); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (a *ConfigAdapter) sourceIndex(name string) int {
+	return slices.IndexFunc(a.sources, func(src *configSource) bool {
+		return src.name == name
+	})
+}
+
+// Function saves a value set by SetValue() so it survives loading
+// of other sources. An item of a list is saved with a copy of the
+// whole list because lists are not merged. The mutex must be locked.
+func (a *ConfigAdapter) setRuntimeValue(val any, path []string) error {
+	merged := mergeSources(a.sources)
+
+	for idx := 1; idx < len(path); idx++ {
+		list, ok := lookupPath(merged, path[:idx])
+
+		if _, isList := list.([]any); !ok || !isList {
+			continue
+		}
+
+		holder := map[string]any{"": list}
+
+		if err := setPath(holder, val, append([]string{""}, path[idx:]...)); err != nil {
+			return err
+		}
+
+		val, path = holder[""], path[:idx]
+
+		break
+	}
+
+	idx := a.sourceIndex(runtimeSourceName)
+
+	if idx < 0 {
+		a.sources = append(a.sources, &configSource{name: runtimeSourceName, layer: LayerRuntime, values: map[string]any{}})
+		idx = len(a.sources) - 1
+	}
+
+	return setPath(a.sources[idx].values, val, path)
+}
+
+// Function sorts sources by layers keeping the order of loading
+// inside a layer.
+func sortSources(sources []*configSource) []*configSource {
+	sorted := slices.Clone(sources)
+
+	slices.SortStableFunc(sorted, func(a, b *configSource) bool {
+		return a.layer < b.layer
+	})
+
+	return sorted
+}
+
+// Function deep merges sources in the order of precedence.
+func mergeSources(sources []*configSource) map[string]any {
+	config := map[string]any{}
+
+	for _, src := range sortSources(sources) {
+		if src.foldKeys {
+			mergeMapsFold(config, src.values)
+		} else {
+			mergeMaps(config, src.values)
+		}
+	}
+
+	return config
+}
+
+// Function merges the source map into the destination one. Nested
+// maps are merged recursively, other values are replaced by copies.
+func mergeMaps(dst map[string]any, src map[string]any) {
+	for key, value := range src {
+		srcMap, srcOk := value.(map[string]any)
+		dstMap, dstOk := dst[key].(map[string]any)
+
+		if srcOk && dstOk {
+			mergeMaps(dstMap, srcMap)
+
+			continue
+		}
+
+		dst[key] = cloneValue(value)
+	}
+}
+
+// Function copies maps and slices recursively.
+func cloneValue(value any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		result := make(map[string]any, len(v))
+
+		for key, item := range v {
+			result[key] = cloneValue(item)
+		}
+
+		return result
+	case []any:
+		result := make([]any, len(v))
+
+		for idx, item := range v {
+			result[idx] = cloneValue(item)
+		}
+
+		return result
+	}
+
+	return value
+}
+
+// Function returns the value of the path in the source. Keys of
+// sources with foldKeys are compared ignoring the case.
+func (src *configSource) lookup(path []string) (any, bool) {
+	if !src.foldKeys {
+		return lookupPath(src.values, path)
+	}
+
+	var value any = src.values
+
+	for _, key := range path {
+		node, ok := value.(map[string]any)
+
+		if !ok {
+			return nil, false
+		}
+
+		if value, ok = node[foldKey(node, key)]; !ok {
+			return nil, false
+		}
+	}
+
+	return value, true
+}
+
+func lookupPath(m map[string]any, path []string) (any, bool) {
+	value, err := walkPath(m, path)
+
+	return value, err == nil
+}
+
+// Function returns the value of the path. Items of lists are
+// addressed by their indexes.
+func walkPath(m map[string]any, path []string) (any, error) {
+	var value any = m
+
+	for idx, key := range path {
+		switch node := value.(type) {
+		case map[string]any:
+			item, ok := node[key]
+
+			if !ok && idx == len(path)-1 {
+				return nil, errors.New("value not found: " + key + " for path " + strings.Join(path, "."))
+			}
+
+			if !ok {
+				return nil, errors.New("path not valid: " + strings.Join(path[:idx+1], "."))
+			}
+
+			value = item
+		case []any:
+			itemIdx, err := listIndex(node, key, path[:idx])
+
+			if err != nil {
+				return nil, err
+			}
+
+			value = node[itemIdx]
+		default:
+			return nil, errors.New("wrong configuration for " + strings.Join(path[:idx], "."))
+		}
+	}
+
+	return value, nil
+}
+
+// Function sets a value creating missing sections. Existing items
+// of lists are addressed by their indexes.
+func setPath(m map[string]any, val any, path []string) error {
+	if len(path) == 0 {
+		return nil
+	}
+
+	var value any = m
+
+	for idx, key := range path {
+		last := idx == len(path)-1
+
+		switch node := value.(type) {
+		case map[string]any:
+			if last {
+				node[key] = val
+
+				return nil
+			}
+
+			if _, ok := node[key]; !ok {
+				node[key] = make(map[string]any)
+			}
+
+			value = node[key]
+		case []any:
+			itemIdx, err := listIndex(node, key, path[:idx])
+
+			if err != nil {
+				return err
+			}
+
+			if last {
+				node[itemIdx] = val
+
+				return nil
+			}
+
+			value = node[itemIdx]
+		default:
+			return errors.New("wrong configuration for " + strings.Join(path[:idx], "."))
+		}
+	}
+
+	return nil
+}
+
+func listIndex(list []any, key string, listPath []string) (int, error) {
+	idx, err := strconv.Atoi(key)
+
+	if err != nil || idx < 0 || idx >= len(list) {
+		return 0, fmt.Errorf("index %s is out of range of the list %s with %d items", key, strings.Join(listPath, "."), len(list))
+	}
+
+	return idx, nil
+}
+
+// Function splits a dotted path like "Adapters.Brokers.0.Host" into
+// keys accepted by getters.
+func SplitPath(path string) []string {
+	if path == "" {
+		return []string{}
+	}
+
+	return strings.Split(path, ".")
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// Options of the "config" tag. Values with commas are quoted with
+// single quotes, e.g. `config:"Hosts,default='a:1,b:2'"`.
+const (
+	TagConfigDefaultName  = "default"
+	TagConfigMinName      = "min"
+	TagConfigMaxName      = "max"
+	TagConfigOneOfName    = "oneof"
+	TagConfigUrlName      = "url"
+	TagConfigHostPortName = "hostport"
+	TagConfigRegexName    = "regex"
+	TagConfigNonEmptyName = "nonempty"
+)
+
+// Error of a value which does not satisfy a rule of the tag.
+var ErrInvalidValue = errors.New("invalid value")
+
+type fieldTag struct {
+	keys         []string
+	required     bool
+	defaultValue *string
+	rules        []fieldRule
+
+	// options which are not known are skipped for compatibility,
+	// e.g. "omitempty" of tags copied from JSON
+	unknown []string
+}
+
+type fieldRule struct {
+	name string
+	arg  string
+}
+
+// Function parses a tag like "Port,required,default=8080,min=1".
+// Unknown options are collected, not rejected.
+func parseTag(tagString string) (tag fieldTag, err error) {
+	options := splitTag(tagString)
+	tag.keys = strings.Split(options[0], ".")
+
+	for _, option := range options[1:] {
+		name, arg, _ := strings.Cut(option, "=")
+
+		switch name {
+		case TagConfigRequiredName:
+			tag.required = true
+		case TagConfigDefaultName:
+			tag.defaultValue = &arg
+		case TagConfigMinName, TagConfigMaxName, TagConfigOneOfName, TagConfigRegexName:
+			if arg == "" {
+				return tag, fmt.Errorf("rule %s of the tag %q has no argument", name, tagString)
+			}
+
+			tag.rules = append(tag.rules, fieldRule{name: name, arg: arg})
+		case TagConfigUrlName, TagConfigHostPortName, TagConfigNonEmptyName:
+			tag.rules = append(tag.rules, fieldRule{name: name})
+		default:
+			tag.unknown = append(tag.unknown, name)
+		}
+	}
+
+	return tag, nil
+}
+
+// Function splits a tag by commas which are not quoted and removes
+// the quotes.
+func splitTag(tagString string) []string {
+	options := []string{}
+	option := strings.Builder{}
+	quoted := false
+
+	for _, c := range tagString {
+		switch {
+		case c == '\'':
+			quoted = !quoted
+		case c == ',' && !quoted:
+			options = append(options, option.String())
+			option.Reset()
+		default:
+			option.WriteRune(c)
+		}
+	}
+
+	return append(options, option.String())
+}
+
+// Function checks whether a field type is decoded from a section.
+func isSection(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// Function checks rules of a field. Empty values which are not set
+// in the configuration are checked only by "nonempty". Rules for
+// strings are applied to every item of lists.
+func (d *decoder) validate(path []string, value reflect.Value, rules []fieldRule, provided bool) {
+	for value.Kind() == reflect.Pointer && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	for _, rule := range rules {
+		if rule.name == TagConfigNonEmptyName {
+			if isEmpty(value) {
+				d.fail(path, fmt.Errorf("%w: must not be empty", ErrInvalidValue))
+			}
+
+			continue
+		}
+
+		if value.Kind() == reflect.Pointer || (!provided && value.IsZero()) {
+			continue
+		}
+
+		if rule.name == TagConfigMinName || rule.name == TagConfigMaxName {
+			if err := checkLimit(value, rule); err != nil {
+				d.fail(path, err)
+			}
+
+			continue
+		}
+
+		if (value.Kind() == reflect.Slice || value.Kind() == reflect.Array) && value.Type().Elem().Kind() == reflect.String {
+			for idx := 0; idx < value.Len(); idx++ {
+				if err := checkString(value.Index(idx), rule); err != nil {
+					d.fail(append(slices.Clone(path), strconv.Itoa(idx)), err)
+				}
+			}
+
+			continue
+		}
+
+		if err := checkString(value, rule); err != nil {
+			d.fail(path, err)
+		}
+	}
+}
+
+func isEmpty(value reflect.Value) bool {
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return value.Len() == 0
+	}
+
+	return value.IsZero()
+}
+
+// Function checks "min" and "max" rules. Numbers and durations are
+// compared by value, strings and collections by length.
+func checkLimit(value reflect.Value, rule fieldRule) error {
+	var actual, limit float64
+	var err error
+
+	switch value.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		actual = float64(value.Len())
+		limit, err = strconv.ParseFloat(rule.arg, 64)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		actual = float64(value.Int())
+
+		if value.Type() == durationType {
+			var duration time.Duration
+
+			duration, err = time.ParseDuration(rule.arg)
+			limit = float64(duration)
+		} else {
+			limit, err = strconv.ParseFloat(rule.arg, 64)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		actual = float64(value.Uint())
+		limit, err = strconv.ParseFloat(rule.arg, 64)
+	case reflect.Float32, reflect.Float64:
+		actual = value.Float()
+		limit, err = strconv.ParseFloat(rule.arg, 64)
+	default:
+		return fmt.Errorf("rule %s cannot be applied to %s", rule.name, value.Type())
+	}
+
+	if err != nil {
+		return fmt.Errorf("rule %s has a wrong argument %q", rule.name, rule.arg)
+	}
+
+	subject := "value"
+
+	if value.Kind() == reflect.String || value.Kind() == reflect.Slice || value.Kind() == reflect.Map || value.Kind() == reflect.Array {
+		subject = "length"
+	}
+
+	if rule.name == TagConfigMinName && actual < limit {
+		return fmt.Errorf("%w: %s must be at least %s", ErrInvalidValue, subject, rule.arg)
+	}
+
+	if rule.name == TagConfigMaxName && actual > limit {
+		return fmt.Errorf("%w: %s must be at most %s", ErrInvalidValue, subject, rule.arg)
+	}
+
+	return nil
+}
+
+// Function checks "oneof", "url", "hostport" and "regex" rules.
+func checkString(value reflect.Value, rule fieldRule) error {
+	text := fmt.Sprint(value.Interface())
+
+	if value.Kind() == reflect.String {
+		text = value.String()
+	}
+
+	switch rule.name {
+	case TagConfigOneOfName:
+		if !slices.Contains(strings.Split(rule.arg, "|"), text) {
+			return fmt.Errorf("%w: %q must be one of %s", ErrInvalidValue, text, strings.ReplaceAll(rule.arg, "|", ", "))
+		}
+	case TagConfigUrlName:
+		if u, err := url.Parse(text); err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("%w: %q must be a URL with a scheme and a host", ErrInvalidValue, text)
+		}
+	case TagConfigHostPortName:
+		_, port, err := net.SplitHostPort(text)
+
+		if err == nil {
+			_, err = strconv.ParseUint(port, 10, 16)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w: %q must be a host:port pair", ErrInvalidValue, text)
+		}
+	case TagConfigRegexName:
+		re, err := regexp.Compile(rule.arg)
+
+		if err != nil {
+			return fmt.Errorf("rule %s has a wrong expression: %v", rule.name, err)
+		}
+
+		if !re.MatchString(text) {
+			return fmt.Errorf("%w: %q must match %s", ErrInvalidValue, text, rule.arg)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testValidated struct {
+	Port     int           `config:"Port,default=8080,min=1,max=65535"`
+	Mode     string        `config:"Mode,default=fast,oneof=fast|slow"`
+	Endpoint string        `config:"Endpoint,url"`
+	Peers    []string      `config:"Peers,hostport"`
+	Name     string        `config:"Name,regex=^[a-z]+$"`
+	Queue    string        `config:"Queue,nonempty"`
+	Timeout  time.Duration `config:"Timeout,default=5s,min=1s,max=1m"`
+	Tags     []string      `config:"Tags,max=2"`
+	Hosts    []string      `config:"Hosts,default='a:1,b:2'"`
+	Legacy   string        `config:"Legacy,omitempty"`
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		path     string
+		message  string
+	}{
+		{"valid", `{"Queue": "q"}`, "", ""},
+		{"all rules pass", `{"Port": 1, "Mode": "slow", "Endpoint": "https://host", "Peers": ["a:1", "b:2"], "Name": "abc", "Queue": "q", "Timeout": "1m", "Tags": ["a", "b"]}`, "", ""},
+		{"unknown option", `{"Queue": "q", "Legacy": "value"}`, "", ""},
+		{"min", `{"Queue": "q", "Port": -1}`, "Port", "value must be at least 1"},
+		{"zero is checked when it is set", `{"Queue": "q", "Port": 0}`, "Port", "value must be at least 1"},
+		{"max", `{"Queue": "q", "Port": 70000}`, "Port", "value must be at most 65535"},
+		{"duration min", `{"Queue": "q", "Timeout": "10ms"}`, "Timeout", "value must be at least 1s"},
+		{"duration max", `{"Queue": "q", "Timeout": 120}`, "Timeout", "value must be at most 1m"},
+		{"length max", `{"Queue": "q", "Tags": "a,b,c"}`, "Tags", "length must be at most 2"},
+		{"oneof", `{"Queue": "q", "Mode": "medium"}`, "Mode", `"medium" must be one of fast, slow`},
+		{"url", `{"Queue": "q", "Endpoint": "host:80"}`, "Endpoint", "must be a URL"},
+		{"hostport", `{"Queue": "q", "Peers": ["a:1", "b"]}`, "Peers.1", "must be a host:port pair"},
+		{"hostport with a wrong port", `{"Queue": "q", "Peers": "a:70000"}`, "Peers.0", "must be a host:port pair"},
+		{"regex", `{"Queue": "q", "Name": "ABC"}`, "Name", "must match ^[a-z]+$"},
+		{"nonempty without a value", `{}`, "Queue", "must not be empty"},
+		{"nonempty with an empty value", `{"Queue": ""}`, "Queue", "must not be empty"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := unmarshalJson(t, test.document, &testValidated{})
+
+			if test.path == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				return
+			}
+
+			fieldErr := &FieldError{}
+
+			if !errors.As(err, &fieldErr) || !errors.Is(err, ErrInvalidValue) {
+				t.Fatalf("expected an invalid value error, got %v", err)
+			}
+
+			if fieldErr.Path != test.path || !strings.Contains(err.Error(), test.message) {
+				t.Errorf("error %q, expected %s: %s", err, test.path, test.message)
+			}
+		})
+	}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	actual := testValidated{}
+
+	if err := unmarshalJson(t, `{"Queue": "q"}`, &actual); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := testValidated{Port: 8080, Mode: "fast", Queue: "q", Timeout: 5 * time.Second, Hosts: []string{"a:1", "b:2"}}
+
+	if !reflect.DeepEqual(actual, expected) {
+		t.Errorf("decoded %+v, expected %+v", actual, expected)
+	}
+}
+
+func TestValidateCollectsViolations(t *testing.T) {
+	err := unmarshalJson(t, `{"Port": 0, "Mode": "medium", "Name": "ABC", "Peers": ["a", "b:1", "c"]}`, &testValidated{})
+
+	if err == nil {
+		t.Fatal("violations are not reported")
+	}
+
+	paths := []string{}
+
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		fieldErr := &FieldError{}
+
+		if errors.As(e, &fieldErr) {
+			paths = append(paths, fieldErr.Path)
+		}
+	}
+
+	expected := []string{"Port", "Mode", "Peers.0", "Peers.2", "Name", "Queue"}
+
+	if !reflect.DeepEqual(paths, expected) {
+		t.Errorf("violations %v, expected %v", paths, expected)
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	tests := []struct {
+		tag      string
+		keys     []string
+		required bool
+		rules    int
+		unknown  []string
+		err      bool
+	}{
+		{"Port", []string{"Port"}, false, 0, nil, false},
+		{"Server.Port,required,min=1", []string{"Server", "Port"}, true, 1, nil, false},
+		{"Hosts,default='a,b',nonempty", []string{"Hosts"}, false, 1, nil, false},
+		{"Name,omitempty,inline", []string{"Name"}, false, 0, []string{"omitempty", "inline"}, false},
+		{"Port,min", nil, false, 0, nil, true},
+		{"Mode,oneof=", nil, false, 0, nil, true},
+	}
+
+	for _, test := range tests {
+		tag, err := parseTag(test.tag)
+
+		if test.err {
+			if err == nil {
+				t.Errorf("tag %q: error is expected", test.tag)
+			}
+
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("tag %q: %v", test.tag, err)
+
+			continue
+		}
+
+		if !reflect.DeepEqual(tag.keys, test.keys) || tag.required != test.required || len(tag.rules) != test.rules || !reflect.DeepEqual(tag.unknown, test.unknown) {
+			t.Errorf("tag %q is parsed to %+v", test.tag, tag)
+		}
+	}
+}
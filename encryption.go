@@ -0,0 +1,128 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/radianteam/framework/adapter/util/config"
+)
+
+// Function runs --generate-config-key and returns the exit code.
+func (rsm *RadianServiceManager) generateKeyCommand(_microservices []string) int {
+	key, err := config.GenerateEncryptionKey()
+
+	if err != nil {
+		rsm.logger.Errorf("key cannot be generated: %v", err)
+
+		return 1
+	}
+
+	fmt.Println(config.EncodeEncryptionKey(key))
+
+	return 0
+}
+
+// Function returns the command of --encrypt-value. The value is
+// encrypted by the key of the environment.
+func (rsm *RadianServiceManager) encryptCommand(value string) func(_microservices []string) int {
+	return func(_microservices []string) int {
+		c, err := config.NewValueCipherFromEnv()
+
+		if err == nil {
+			value, err = c.Encrypt(value)
+		}
+
+		if err != nil {
+			rsm.logger.Errorf("value cannot be encrypted: %v", err)
+
+			return 1
+		}
+
+		fmt.Println(value)
+
+		return 0
+	}
+}
+
+// Function returns the command of --decrypt-value.
+func (rsm *RadianServiceManager) decryptCommand(value string) func(_microservices []string) int {
+	return func(_microservices []string) int {
+		c, err := config.NewValueCipherFromEnv()
+
+		if err == nil {
+			value, err = c.Decrypt(value)
+		}
+
+		if err != nil {
+			rsm.logger.Errorf("value cannot be decrypted: %v", err)
+
+			return 1
+		}
+
+		fmt.Println(value)
+
+		return 0
+	}
+}
+
+// Function returns the command of --rotate-config-key. Encrypted
+// values of the loaded configuration files are decrypted by the keys
+// of the environment and encrypted by the first key of the file.
+func (rsm *RadianServiceManager) rotateKeyCommand(keyFileName string) func(_microservices []string) int {
+	return func(_microservices []string) int {
+		if err := rsm.rotateKey(keyFileName); err != nil {
+			rsm.logger.Errorf("key cannot be rotated: %v", err)
+
+			return 1
+		}
+
+		return 0
+	}
+}
+
+func (rsm *RadianServiceManager) rotateKey(keyFileName string) error {
+	from, err := config.NewValueCipherFromEnv()
+
+	if err != nil {
+		return err
+	}
+
+	to, err := config.NewValueCipherFromFile(keyFileName)
+
+	if err != nil {
+		return err
+	}
+
+	files := rsm.mainConfig.GetFiles()
+
+	if len(files) == 0 {
+		return errors.New("no configuration file is loaded, use the -c flag")
+	}
+
+	for _, fileName := range files {
+		info, err := os.Stat(fileName)
+
+		if err != nil {
+			return err
+		}
+
+		data, err := os.ReadFile(fileName)
+
+		if err != nil {
+			return err
+		}
+
+		if data, err = config.RotateEncryptedValues(data, from, to); err != nil {
+			return fmt.Errorf("file %s: %w", fileName, err)
+		}
+
+		if err = os.WriteFile(fileName, data, info.Mode().Perm()); err != nil {
+			return err
+		}
+
+		rsm.logger.Infof("values of the file %s are encrypted by the new key", fileName)
+	}
+
+	return nil
+}
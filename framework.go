@@ -1,21 +1,24 @@
 package framework
 
-// TODO: healthchecks
 // TODO: tests
-// TODO: make permanent workers
-// TODO: remove fatal behaviour and return error everywhere
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/radianteam/framework/adapter/util/config"
+	"github.com/radianteam/framework/worker"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
 	"golang.org/x/exp/slices"
 )
 
@@ -32,8 +35,26 @@ type RadianServiceManager struct {
 
 	desiredServiceNames []string
 
+	mutex                    sync.Mutex
+	runningMicroserviceNames []string
+
 	mainConfig *config.ConfigAdapter
 
+	registry *RadianRegistry
+
+	adapters *worker.AdapterPool
+
+	configWatchInterval time.Duration
+
+	// main configuration file, files of profiles and microservices
+	// are searched near it
+	configPath string
+
+	// command of the command line run instead of microservices
+	command func(_microservices []string) int
+
+	state lifecycle
+
 	logger *logrus.Entry
 }
 
@@ -46,6 +67,8 @@ func NewRadianServiceManager() *RadianServiceManager {
 	return &RadianServiceManager{
 		microservices: make(MicroserviceMap),
 		mainConfig:    config.NewConfigAdapter("Config"),
+		registry:      NewRadianRegistry(),
+		adapters:      worker.NewAdapterPool(),
 		logger:        logger.WithField("manager", "framework"),
 	}
 }
@@ -85,12 +108,68 @@ func (rsm *RadianServiceManager) AddMicroserviceCreator(name string, creator Mic
 	return nil
 }
 
+// AddMicroserviceFromConfig registers a microservice that is built
+// by the registry from the "Adapters" and "Workers" keys of its
+// configuration section. The setup function is optional and is
+// called after the microservice has been built.
+func (rsm *RadianServiceManager) AddMicroserviceFromConfig(name string, setup MicroserviceSetupFunc) error {
+	return rsm.AddMicroserviceCreator(name, func(name string, configAdapter *config.ConfigAdapter) (*RadianMicroservice, error) {
+		ms, err := rsm.registry.CreateMicroservice(name, configAdapter)
+
+		if err != nil {
+			return nil, err
+		}
+
+		if setup != nil {
+			if err = setup(ms); err != nil {
+				return nil, err
+			}
+		}
+
+		return ms, nil
+	})
+}
+
+// AddAdapterType registers a custom adapter type that can be
+// used in the "Adapters" configuration section.
+func (rsm *RadianServiceManager) AddAdapterType(typeName string, creator AdapterCreatorFunc) error {
+	return rsm.registry.AddAdapterType(typeName, creator)
+}
+
+// AddWorkerType registers a custom worker type that can be
+// used in the "Workers" configuration section.
+func (rsm *RadianServiceManager) AddWorkerType(typeName string, creator WorkerCreatorFunc) error {
+	return rsm.registry.AddWorkerType(typeName, creator)
+}
+
+// Function returns the main configuration of the manager. Use it to
+// add sources, secret providers or subscribers.
+func (rsm *RadianServiceManager) GetConfig() *config.ConfigAdapter {
+	return rsm.mainConfig
+}
+
+// Function returns the type registry of the manager.
+func (rsm *RadianServiceManager) GetRegistry() *RadianRegistry {
+	return rsm.registry
+}
+
 func (rsm *RadianServiceManager) SetupFromCommandLine() (err error) {
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 
 	var argOpts struct {
-		Config string `short:"c" long:"config" description:"A configuration file name"`
+		Config string `short:"c" long:"config" description:"A configuration file name (.json, .jsonc, .yaml, .yml or .toml)"`
 		Mode   string `short:"m" long:"mode" description:"all, monolith, empty string or service names comma separated"`
+
+		Profile string `short:"p" long:"profile" env:"RADIAN_PROFILE" description:"Environment profile; config.<profile>.json overrides config.json"`
+
+		ValidateConfig    bool `long:"validate-config" description:"Load and validate the configuration of microservices and exit"`
+		PrintConfig       bool `long:"print-config" description:"Print the effective configuration with redacted secrets and exit"`
+		PrintConfigSchema bool `long:"print-config-schema" description:"Print the JSON Schema of the configuration and exit"`
+
+		GenerateConfigKey bool   `long:"generate-config-key" description:"Print a new key of encrypted configuration values and exit"`
+		EncryptValue      string `long:"encrypt-value" description:"Print the value encrypted by the configuration key and exit"`
+		DecryptValue      string `long:"decrypt-value" description:"Print the decrypted \"enc:\" value and exit"`
+		RotateConfigKey   string `long:"rotate-config-key" description:"Encrypt values of the configuration file again by the key of the file and exit"`
 	}
 
 	_, err = flags.ParseArgs(&argOpts, os.Args)
@@ -99,7 +178,24 @@ func (rsm *RadianServiceManager) SetupFromCommandLine() (err error) {
 		return
 	}
 
-	return rsm.setupInternal(argOpts.Mode, argOpts.Config)
+	switch {
+	case argOpts.ValidateConfig:
+		rsm.command = rsm.validateCommand
+	case argOpts.PrintConfig:
+		rsm.command = rsm.printConfigCommand
+	case argOpts.PrintConfigSchema:
+		rsm.command = rsm.printConfigSchemaCommand
+	case argOpts.GenerateConfigKey:
+		rsm.command = rsm.generateKeyCommand
+	case argOpts.EncryptValue != "":
+		rsm.command = rsm.encryptCommand(argOpts.EncryptValue)
+	case argOpts.DecryptValue != "":
+		rsm.command = rsm.decryptCommand(argOpts.DecryptValue)
+	case argOpts.RotateConfigKey != "":
+		rsm.command = rsm.rotateKeyCommand(argOpts.RotateConfigKey)
+	}
+
+	return rsm.setupInternal(argOpts.Mode, argOpts.Config, argOpts.Profile)
 }
 
 func (rsm *RadianServiceManager) SetupFromEnv(prefix string) (err error) {
@@ -107,16 +203,23 @@ func (rsm *RadianServiceManager) SetupFromEnv(prefix string) (err error) {
 
 	confAdapter := config.NewConfigAdapter("temp")
 
+	// variables are matched with the keys ignoring the case
+	err = confAdapter.SetDefaults(map[string]any{"Mode": "", "Config": "", "Profile": ""})
+
+	if err != nil {
+		return
+	}
+
 	err = confAdapter.LoadFromEnv(prefix)
 
 	if err != nil {
 		return
 	}
 
-	return rsm.setupInternal(confAdapter.GetStringOrDefault("", "Mode"), confAdapter.GetStringOrDefault("", "Config"))
+	return rsm.setupInternal(confAdapter.GetStringOrDefault("", "Mode"), confAdapter.GetStringOrDefault("", "Config"), confAdapter.GetStringOrDefault("", "Profile"))
 }
 
-func (rsm *RadianServiceManager) setupInternal(mode string, _config string) (err error) {
+func (rsm *RadianServiceManager) setupInternal(mode string, _config string, profile string) (err error) {
 	names := strings.Split(mode, ",")
 
 	if mode != "all" {
@@ -129,93 +232,451 @@ func (rsm *RadianServiceManager) setupInternal(mode string, _config string) (err
 		}
 	}
 
-	if _config != "" {
-		logrus.Infof("Loading configuration from file: %s", _config)
+	rsm.mainConfig.SetProfile(profile)
 
-		err = rsm.mainConfig.LoadFromFileJson(_config)
+	rsm.configPath = _config
 
-		if err != nil {
+	if _config == "" {
+		// the default file is loaded only for a profile and is optional,
+		// so a binary without flags does not pick up a stray file
+		if profile == "" || !fileExists(config.DefaultJsonConfigPath) {
 			return
 		}
+
+		rsm.configPath = config.DefaultJsonConfigPath
 	}
 
-	return
+	logrus.Infof("Loading configuration from file: %s", rsm.configPath)
+
+	err = rsm.mainConfig.LoadFromFile(rsm.configPath)
+
+	if err != nil {
+		return
+	}
+
+	if profile == "" {
+		return
+	}
+
+	overlay := profilePath(rsm.configPath, profile)
+
+	if !fileExists(overlay) {
+		logrus.Warnf("Configuration file of the profile %s is not found: %s", profile, overlay)
+
+		return
+	}
+
+	logrus.Infof("Loading configuration of the profile %s from file: %s", profile, overlay)
+
+	return rsm.mainConfig.LoadOverlayFromFile(overlay)
 }
 
-// Main framework loop. Runs all microservices. The loop setups
-// microservices, captures the thread and wait for SIGINT or SIGTERM
-// signals. After termination releases the thread.
-func (rsm *RadianServiceManager) RunAll() {
-	rsm.Run(rsm.microserviceNames)
+// Function loads files of microservices from the directory of the
+// main configuration file, e.g. "main.json" and "main.production.json"
+// into the section "main". Missing files are skipped.
+func (rsm *RadianServiceManager) loadServiceFiles(_microservices []string) error {
+	if rsm.configPath == "" {
+		return nil
+	}
+
+	profile := rsm.mainConfig.GetProfile()
+
+	for _, serviceName := range _microservices {
+		base := filepath.Join(filepath.Dir(rsm.configPath), serviceName+filepath.Ext(rsm.configPath))
+
+		if base == filepath.Clean(rsm.configPath) {
+			continue
+		}
+
+		files := []string{base}
+		layers := []config.ConfigLayer{config.LayerFile}
+
+		if profile != "" {
+			files = append(files, profilePath(base, profile))
+			layers = append(layers, config.LayerOverlay)
+		}
+
+		for idx, fileName := range files {
+			if !fileExists(fileName) {
+				continue
+			}
+
+			rsm.logger.Infof("Loading configuration of the microservice %s from file: %s", serviceName, fileName)
+
+			if err := rsm.mainConfig.LoadSectionFromFile(fileName, layers[idx], serviceName); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
 }
 
-func (rsm *RadianServiceManager) RunDesired() {
-	if len(rsm.desiredServiceNames) == 0 {
-		rsm.RunAll()
+// GetProfile returns the environment profile set by the --profile
+// flag or the RADIAN_PROFILE variable. Creators of microservices,
+// adapters and workers get it by configAdapter.GetProfile().
+func (rsm *RadianServiceManager) GetProfile() string {
+	return rsm.mainConfig.GetProfile()
+}
 
-		return
+// Function returns the name of the profile file, e.g.
+// "config.production.json" for "config.json".
+func profilePath(fileName string, profile string) string {
+	ext := filepath.Ext(fileName)
+
+	return strings.TrimSuffix(fileName, ext) + "." + profile + ext
+}
+
+func fileExists(fileName string) bool {
+	_, err := os.Stat(fileName)
+
+	return err == nil
+}
+
+// Main framework loop. Runs all microservices including ones
+// declared only in the configuration. The loop setups microservices,
+// captures the thread and wait for SIGINT or SIGTERM signals. After
+// termination releases the thread.
+func (rsm *RadianServiceManager) RunAll() error {
+	return rsm.Run(append(slices.Clone(rsm.microserviceNames), rsm.declaredMicroserviceNames()...))
+}
+
+// Function returns names of microservices which are not registered
+// but have the "Workers" section in the main configuration.
+func (rsm *RadianServiceManager) declaredMicroserviceNames() []string {
+	names := []string{}
+
+	for _, name := range sortedKeys(rsm.mainConfig) {
+		if slices.Contains(rsm.microserviceNames, name) {
+			continue
+		}
+
+		if isDeclaredMicroservice(rsm.mainConfig.GetAdapterOrNil(name)) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func (rsm *RadianServiceManager) RunDesired() error {
+	if len(rsm.desiredServiceNames) == 0 {
+		return rsm.RunAll()
 	}
 
-	rsm.Run(rsm.desiredServiceNames)
+	return rsm.Run(rsm.desiredServiceNames)
 }
 
 // Main framework loop. The loop runs microservices in different
 // goroutines, captures the thread and wait for SIGINT or SIGTERM
-// signals. After termination releases the thread.
-func (rsm *RadianServiceManager) Run(_microservices []string) {
+// signals or a microservice failure. After termination stops all
+// microservices, releases the thread and returns their errors.
+func (rsm *RadianServiceManager) Run(_microservices []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := rsm.start(ctx, _microservices); err != nil {
+		return err
+	}
+
+	return rsm.Wait()
+}
+
+// Start runs desired microservices (or all of them if nothing is
+// desired) in background and returns immediately. Microservices are
+// stopped when the context is cancelled, one of them fails or
+// Shutdown() is called.
+func (rsm *RadianServiceManager) Start(ctx context.Context) error {
+	if len(rsm.desiredServiceNames) == 0 {
+		return rsm.start(ctx, append(slices.Clone(rsm.microserviceNames), rsm.declaredMicroserviceNames()...))
+	}
+
+	return rsm.start(ctx, rsm.desiredServiceNames)
+}
+
+// Shutdown stops started microservices and returns their errors
+// like Wait(). If the context is done earlier the context error is
+// returned and microservices keep stopping in background.
+func (rsm *RadianServiceManager) Shutdown(ctx context.Context) error {
+	if err := rsm.state.shutdown(ctx); err != nil && !errors.Is(err, errNotStarted) {
+		return err
+	}
+
+	return nil
+}
+
+// Wait blocks until started microservices are stopped and returns
+// their errors.
+func (rsm *RadianServiceManager) Wait() error {
+	if err := rsm.state.wait(); err != nil {
+		if errors.Is(err, errNotStarted) {
+			return fmt.Errorf("manager is %w", err)
+		}
+
+		return err
+	}
+
+	return nil
+}
+
+// Ready returns a channel which is closed when all started
+// microservices are ready.
+func (rsm *RadianServiceManager) Ready() <-chan struct{} {
+	return rsm.state.readyChan()
+}
+
+// Function creates microservices and starts them in background.
+func (rsm *RadianServiceManager) start(ctx context.Context, _microservices []string) error {
 	logrus.SetFormatter(&logrus.JSONFormatter{})
 
+	if err := rsm.loadServiceFiles(_microservices); err != nil {
+		return err
+	}
+
+	// command line commands run instead of microservices
+	if rsm.command != nil {
+		return &CommandExitError{Code: rsm.command(_microservices)}
+	}
+
 	rsm.logger.Info("running")
 
 	// check microservice names
 	for _, serviceName := range _microservices {
 		if _, ok := rsm.microservices[serviceName]; !ok {
-			if _, ok := rsm.microserviceCreators[serviceName]; !ok {
-				availWorkers := []string{}
-				for k := range rsm.microservices {
-					availWorkers = append(availWorkers, k)
-				}
-				for k := range rsm.microserviceCreators {
-					availWorkers = append(availWorkers, k)
-				}
-
-				rsm.logger.Fatalf("worker with name %s is not found. Use \"all\" or names: %s", serviceName, strings.Join(availWorkers, ", "))
+			creator, err := rsm.getCreator(serviceName)
+
+			if err != nil {
+				return err
 			}
 
-			ms, err := rsm.microserviceCreators[serviceName](serviceName, rsm.mainConfig.GetAdapterOrNil(serviceName))
+			ms, err := creator(serviceName, rsm.mainConfig.GetAdapterOrNil(serviceName))
 
 			if err != nil {
-				rsm.logger.Fatalf("worker with name %s created with the error: %v", serviceName, err)
+				return fmt.Errorf("worker with name %s created with the error: %w", serviceName, err)
 			}
 
+			rsm.mutex.Lock()
 			rsm.microservices[serviceName] = ms
+			rsm.mutex.Unlock()
+		}
+	}
+
+	ctx, cancel, err := rsm.state.begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("manager is %w", err)
+	}
+
+	// in monolith mode health endpoints report all microservices
+	rsm.mutex.Lock()
+	rsm.runningMicroserviceNames = _microservices
+	rsm.mutex.Unlock()
+
+	if len(_microservices) > 1 {
+		for _, name := range _microservices {
+			rsm.microservices[name].setHealthProvider(rsm)
 		}
 	}
 
-	// run workers
+	// start microservices
+	started := []*RadianMicroservice{}
+
+	for _, name := range _microservices {
+		rsm.logger.Infof("microservice %s: running", name)
+
+		ms := rsm.microservices[name]
+		ms.GetAdapterPool().SetParent(rsm.adapters)
+
+		if err := ms.Start(ctx); err != nil {
+			cancel()
+
+			for _, ms := range started {
+				ms.Wait()
+			}
+
+			rsm.state.finish(err)
+
+			return err
+		}
+
+		started = append(started, ms)
+	}
+
+	go rsm.watchReadiness(ctx, started)
+	go rsm.watchConfig(ctx)
+
+	go func() {
+		rsm.state.finish(rsm.run(ctx, cancel, started))
+	}()
+
+	return nil
+}
+
+// Function returns the creator of a microservice which is not
+// registered as an instance.
+func (rsm *RadianServiceManager) getCreator(serviceName string) (MicroserviceCreatorFunc, error) {
+	creator, ok := rsm.microserviceCreators[serviceName]
+
+	if !ok && isDeclaredMicroservice(rsm.mainConfig.GetAdapterOrNil(serviceName)) {
+		creator, ok = rsm.registry.CreateMicroservice, true
+	}
+
+	if !ok {
+		availWorkers := []string{}
+		for k := range rsm.microservices {
+			availWorkers = append(availWorkers, k)
+		}
+		for k := range rsm.microserviceCreators {
+			availWorkers = append(availWorkers, k)
+		}
+		availWorkers = append(availWorkers, rsm.declaredMicroserviceNames()...)
+
+		return nil, fmt.Errorf("worker with name %s is not found. Use \"all\" or names: %s", serviceName, strings.Join(availWorkers, ", "))
+	}
+
+	return creator, nil
+}
+
+// Function waits for microservices and stops all of them when
+// one fails.
+func (rsm *RadianServiceManager) run(ctx context.Context, cancel context.CancelFunc, _microservices []*RadianMicroservice) error {
+	errs := []error{}
+	results := make(chan error, len(_microservices))
 	wg := sync.WaitGroup{}
 
-	for _, microserviceName := range _microservices {
+	for _, microservice := range _microservices {
 		wg.Add(1)
 
-		go func(name string) {
+		go func(ms *RadianMicroservice) {
 			defer wg.Done()
 
-			rsm.logger.Infof("microservice %s: running", name)
-			rsm.microservices[name].RunAll() // TODO: return and check error
+			if err := ms.Wait(); err != nil {
+				results <- fmt.Errorf("microservice %s: %w", ms.GetName(), err)
+			}
 
-			rsm.logger.Infof("microservice %s: stopped", name)
-		}(microserviceName)
+			rsm.logger.Infof("microservice %s: stopped", ms.GetName())
+		}(microservice)
 	}
 
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case <-ctx.Done():
+	case err := <-results:
+		rsm.logger.Error(err)
 
-	<-done
+		errs = append(errs, err)
+	}
 
 	rsm.logger.Info("stopping workers")
 
+	cancel()
+
 	wg.Wait()
+	close(results)
+
+	for err := range results {
+		errs = append(errs, err)
+	}
 
 	rsm.logger.Info("stopped")
+
+	return errors.Join(errs...)
+}
+
+// Function notifies Ready() waiters when all microservices are ready.
+func (rsm *RadianServiceManager) watchReadiness(ctx context.Context, _microservices []*RadianMicroservice) {
+	for _, ms := range _microservices {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ms.Ready():
+		}
+	}
+
+	rsm.logger.Info("ready")
+	rsm.state.markReady()
+}
+
+// Function enables polling of configuration files for changes.
+// A changed file reloads the configuration like SIGHUP. Zero
+// disables polling.
+func (rsm *RadianServiceManager) SetConfigWatchInterval(interval time.Duration) {
+	rsm.configWatchInterval = interval
+}
+
+// Reload loads the configuration sources again and notifies
+// subscribers about changed values. Workers with "RestartOnChange"
+// are restarted when their sections are changed. If a source fails
+// the current configuration is kept.
+func (rsm *RadianServiceManager) Reload() error {
+	rsm.logger.Info("reloading configuration")
+
+	changes, err := rsm.mainConfig.Reload()
+
+	if err != nil {
+		return fmt.Errorf("configuration reload error: %w", err)
+	}
+
+	paths := []string{}
+
+	for _, change := range changes {
+		paths = append(paths, change.String())
+	}
+
+	rsm.logger.Infof("configuration is reloaded, changed keys: %s", strings.Join(paths, ", "))
+
+	return nil
+}
+
+// Function reloads the configuration on SIGHUP and on changes of
+// configuration files until the context is done.
+func (rsm *RadianServiceManager) watchConfig(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var tick <-chan time.Time
+
+	if rsm.configWatchInterval > 0 {
+		ticker := time.NewTicker(rsm.configWatchInterval)
+		defer ticker.Stop()
+
+		tick = ticker.C
+	}
+
+	modTimes := configModTimes(rsm.mainConfig.GetFiles())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+		case <-tick:
+			current := configModTimes(rsm.mainConfig.GetFiles())
+
+			if maps.Equal(current, modTimes) {
+				continue
+			}
+		}
+
+		if err := rsm.Reload(); err != nil {
+			rsm.logger.Error(err)
+		}
+
+		modTimes = configModTimes(rsm.mainConfig.GetFiles())
+	}
+}
+
+// Function returns modification times of files. Missing files
+// are skipped.
+func configModTimes(files []string) map[string]time.Time {
+	times := make(map[string]time.Time)
+
+	for _, file := range files {
+		if info, err := os.Stat(file); err == nil {
+			times[file] = info.ModTime()
+		}
+	}
+
+	return times
 }
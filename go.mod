@@ -1,8 +1,9 @@
 module github.com/radianteam/framework
 
-go 1.19
+go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/arangodb/go-driver v1.3.3
 	github.com/aws/aws-sdk-go v1.44.122
 	github.com/coreos/go-oidc/v3 v3.4.0
@@ -20,6 +21,7 @@ require (
 	golang.org/x/exp v0.0.0-20220722155223-a9213eeb770e
 	golang.org/x/net v0.0.0-20220826154423-83b083e8dc8b
 	google.golang.org/grpc v1.47.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -64,5 +66,4 @@ require (
 	google.golang.org/protobuf v1.28.1 // indirect
 	gopkg.in/square/go-jose.v2 v2.6.0 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.0-20210107192922-496545a6307b // indirect
 )
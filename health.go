@@ -0,0 +1,94 @@
+package framework
+
+import (
+	"context"
+	"errors"
+
+	"github.com/radianteam/framework/worker"
+)
+
+var (
+	errWorkerNotReady = errors.New("worker is not ready")
+	errShuttingDown   = errors.New("microservice is shutting down")
+)
+
+// Function checks adapters of all running workers. Check names
+// have the "<worker>.<adapter>" format.
+func (r *RadianMicroservice) CheckHealth(ctx context.Context) *worker.HealthReport {
+	report := worker.NewHealthReport()
+
+	for _, name := range r.getRunningWorkerNames() {
+		report.Merge(name+".", r.getWorker(name).CheckHealth(ctx))
+	}
+
+	return report
+}
+
+// Function checks adapters and readiness of all running workers.
+// A worker is ready when it listens for requests or consumes events.
+// The microservice is not ready as soon as the shutdown is started.
+func (r *RadianMicroservice) CheckReadiness(ctx context.Context) *worker.HealthReport {
+	report := r.CheckHealth(ctx)
+
+	if r.draining.Load() {
+		report.AddCheck("shutdown", errShuttingDown)
+	}
+
+	for _, name := range r.getRunningWorkerNames() {
+		if r.getWorker(name).IsReady() {
+			report.AddCheck(name, nil)
+		} else {
+			report.AddCheck(name, errWorkerNotReady)
+		}
+	}
+
+	return report
+}
+
+// Function aggregates health reports of running microservices.
+// Check names have the "<microservice>.<worker>.<adapter>" format.
+func (rsm *RadianServiceManager) CheckHealth(ctx context.Context) *worker.HealthReport {
+	report := worker.NewHealthReport()
+
+	for name, ms := range rsm.runningMicroservices() {
+		report.Merge(name+".", ms.CheckHealth(ctx))
+	}
+
+	return report
+}
+
+// Function aggregates readiness reports of running microservices.
+func (rsm *RadianServiceManager) CheckReadiness(ctx context.Context) *worker.HealthReport {
+	report := worker.NewHealthReport()
+
+	for name, ms := range rsm.runningMicroservices() {
+		report.Merge(name+".", ms.CheckReadiness(ctx))
+	}
+
+	return report
+}
+
+// Function returns running microservices by name. The map is
+// copied under the mutex, microservices are checked without it.
+func (rsm *RadianServiceManager) runningMicroservices() map[string]*RadianMicroservice {
+	rsm.mutex.Lock()
+	defer rsm.mutex.Unlock()
+
+	running := make(map[string]*RadianMicroservice, len(rsm.runningMicroserviceNames))
+
+	for _, name := range rsm.runningMicroserviceNames {
+		running[name] = rsm.microservices[name]
+	}
+
+	return running
+}
+
+// Function sets the health provider to all workers of the
+// microservice which expose health reports.
+func (r *RadianMicroservice) setHealthProvider(provider worker.HealthProviderInterface) {
+	for _, w := range r.workers {
+		if consumer, ok := w.(worker.HealthConsumerInterface); ok {
+			consumer.SetHealthProvider(provider)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package framework
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/radianteam/framework/adapter/util/config"
+)
+
+// Error returned by Run(), RunAll(), RunDesired() and Start() when a
+// command line flag like --validate-config has been run instead of
+// microservices. The code is the exit code of the command.
+type CommandExitError struct {
+	Code int
+}
+
+func (e *CommandExitError) Error() string {
+	return fmt.Sprintf("command line command is finished with the exit code %d", e.Code)
+}
+
+// ExitCode returns the exit code of the process for an error of
+// Run(): the code of a command line command, 1 for other errors and
+// 0 for nil.
+//
+//	os.Exit(framework.ExitCode(manager.RunDesired()))
+func ExitCode(err error) int {
+	var commandErr *CommandExitError
+
+	if errors.As(err, &commandErr) {
+		return commandErr.Code
+	}
+
+	if err != nil {
+		return 1
+	}
+
+	return 0
+}
+
+// ConfigJsonSchema returns the JSON Schema of the main configuration.
+// Every section with the "Workers" key is described as a microservice
+// built by the registry, other sections are not restricted.
+func (rsm *RadianServiceManager) ConfigJsonSchema() map[string]any {
+	return map[string]any{
+		"$schema": config.JsonSchemaVersion,
+		"title":   "Configuration of microservices",
+		"type":    "object",
+		"additionalProperties": map[string]any{
+			"if":   map[string]any{"type": "object", "required": []string{ConfigWorkersSection}},
+			"then": rsm.registry.MicroserviceJsonSchema(),
+		},
+	}
+}
+
+// WriteConfigSchema writes the JSON Schema of the main configuration.
+func (rsm *RadianServiceManager) WriteConfigSchema(w io.Writer) error {
+	return writeJson(w, rsm.ConfigJsonSchema())
+}
+
+// WriteEffectiveConfig writes the merged configuration of all
+// sources. Secrets are redacted, see config.SecretKeyNames.
+func (rsm *RadianServiceManager) WriteEffectiveConfig(w io.Writer) error {
+	value, err := rsm.mainConfig.GetRedactedValue()
+
+	if err != nil {
+		return err
+	}
+
+	return writeJson(w, value)
+}
+
+// ValidateConfig creates microservices with the names from their
+// configuration sections without starting them. Errors of all
+// microservices are returned together. Microservices registered as
+// instances are not checked.
+func (rsm *RadianServiceManager) ValidateConfig(_microservices []string) error {
+	errs := []error{}
+
+	for _, serviceName := range _microservices {
+		if _, ok := rsm.microservices[serviceName]; ok {
+			continue
+		}
+
+		creator, err := rsm.getCreator(serviceName)
+
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		if _, err = creator(serviceName, rsm.mainConfig.GetAdapterOrNil(serviceName)); err != nil {
+			errs = append(errs, fmt.Errorf("microservice %s: %w", serviceName, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Function runs --validate-config and returns the exit code.
+func (rsm *RadianServiceManager) validateCommand(_microservices []string) int {
+	if err := rsm.ValidateConfig(_microservices); err != nil {
+		rsm.logger.Errorf("configuration is not valid: %v", err)
+
+		return 1
+	}
+
+	rsm.logger.Info("configuration is valid")
+
+	return 0
+}
+
+// Function runs --print-config and returns the exit code.
+func (rsm *RadianServiceManager) printConfigCommand(_microservices []string) int {
+	if err := rsm.WriteEffectiveConfig(os.Stdout); err != nil {
+		rsm.logger.Errorf("configuration cannot be printed: %v", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+// Function runs --print-config-schema and returns the exit code.
+func (rsm *RadianServiceManager) printConfigSchemaCommand(_microservices []string) int {
+	if err := rsm.WriteConfigSchema(os.Stdout); err != nil {
+		rsm.logger.Errorf("configuration schema cannot be printed: %v", err)
+
+		return 1
+	}
+
+	return 0
+}
+
+func writeJson(w io.Writer, value any) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(value)
+}
@@ -0,0 +1,137 @@
+package framework
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Interval between readiness checks of workers after start.
+const ReadinessPollInterval = 100 * time.Millisecond
+
+var (
+	errAlreadyRunning = errors.New("already running")
+	errNotStarted     = errors.New("not started")
+)
+
+// Structure keeps the state of a microservice or a manager
+// started in background: the cancel function of the run context,
+// the result and the ready notification.
+type lifecycle struct {
+	mutex sync.Mutex
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	ready  chan struct{}
+	err    error
+}
+
+// Function prepares a new run. It returns an error if the
+// previous run is not finished yet.
+func (l *lifecycle) begin(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.done != nil && !isClosed(l.done) {
+		return nil, nil, errAlreadyRunning
+	}
+
+	ctx, l.cancel = context.WithCancel(ctx)
+	l.done = make(chan struct{})
+	l.err = nil
+
+	if l.ready == nil || isClosed(l.ready) {
+		l.ready = make(chan struct{})
+	}
+
+	return ctx, l.cancel, nil
+}
+
+// Function saves the result of the run and releases waiters.
+func (l *lifecycle) finish(err error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.err = err
+	l.cancel()
+
+	close(l.done)
+}
+
+// Function returns true if the run is started and not finished.
+func (l *lifecycle) isRunning() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.done != nil && !isClosed(l.done)
+}
+
+func (l *lifecycle) markReady() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !isClosed(l.ready) {
+		close(l.ready)
+	}
+}
+
+func (l *lifecycle) readyChan() <-chan struct{} {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.ready == nil {
+		l.ready = make(chan struct{})
+	}
+
+	return l.ready
+}
+
+func (l *lifecycle) wait() error {
+	l.mutex.Lock()
+	done := l.done
+	l.mutex.Unlock()
+
+	if done == nil {
+		return errNotStarted
+	}
+
+	<-done
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.err
+}
+
+// Function cancels the run and waits for its result until the
+// context is done.
+func (l *lifecycle) shutdown(ctx context.Context) error {
+	l.mutex.Lock()
+	cancel, done := l.cancel, l.done
+	l.mutex.Unlock()
+
+	if done == nil {
+		return errNotStarted
+	}
+
+	cancel()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return fmt.Errorf("shutdown is not completed: %w", ctx.Err())
+	}
+
+	return l.wait()
+}
+
+func isClosed(c chan struct{}) bool {
+	select {
+	case <-c:
+		return true
+	default:
+		return false
+	}
+}
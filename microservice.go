@@ -2,14 +2,19 @@ package framework
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/signal"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
 
 	"github.com/radianteam/framework/worker"
 	"github.com/radianteam/framework/worker/task/job"
@@ -30,6 +35,26 @@ type RadianMicroservice struct {
 	workers     WorkersMap
 	workerNames []string
 
+	runningWorkerNames []string
+
+	mutex    sync.Mutex
+	failures []error
+
+	restartPolicies map[string]RestartPolicy
+	shutdownConfig  *ShutdownConfig
+	draining        atomic.Bool
+
+	workerCancels    map[string]context.CancelFunc
+	workerRestarts   map[string]bool
+	replacements     map[string]worker.WorkerInterface
+	reconfigurations map[string]func(w worker.WorkerInterface) error
+
+	acquiredAdapterNames []string
+
+	adapters *worker.AdapterPool
+
+	state lifecycle
+
 	logger *logrus.Entry
 }
 
@@ -42,9 +67,10 @@ func NewRadianMicroservice(name string) *RadianMicroservice {
 	w := make(WorkersMap)
 
 	return &RadianMicroservice{
-		workers: w,
-		name:    name,
-		logger:  logger.WithField("microservice", name),
+		workers:  w,
+		name:     name,
+		adapters: worker.NewAdapterPool(),
+		logger:   logger.WithField("microservice", name),
 	}
 }
 
@@ -62,6 +88,10 @@ func (r *RadianMicroservice) AddWorker(w worker.WorkerInterface) error {
 
 	w.SetMicroserviceName(r.GetName())
 
+	if consumer, ok := w.(worker.HealthConsumerInterface); ok {
+		consumer.SetHealthProvider(r)
+	}
+
 	r.workers[w.GetName()] = w
 
 	r.workerNames = append(r.workerNames, w.GetName())
@@ -107,6 +137,16 @@ func (r *RadianMicroservice) AddPostJob(t *job.TaskJob) error {
 	return nil
 }
 
+// GetWorker returns a registered worker by name. Use it to set
+// routes, events or tasks of workers built from the configuration.
+func (r *RadianMicroservice) GetWorker(name string) (worker.WorkerInterface, error) {
+	if w := r.getWorker(name); w != nil {
+		return w, nil
+	}
+
+	return nil, fmt.Errorf("worker with name %s is not found", name)
+}
+
 // Returns the name of a microservice
 func (r *RadianMicroservice) GetName() string {
 	return r.name
@@ -116,90 +156,158 @@ func (r *RadianMicroservice) GetName() string {
 // run jobs. Use RunWithJobs() instead of Run(). The loop setups
 // adapters, captures the thread and wait for SIGINT or SIGTERM
 // signals. After termination runs postjobs and releases the thread.
-func (r *RadianMicroservice) Run(_workers []string) {
-	r.RunWithJobs([]string{}, _workers, []string{})
+func (r *RadianMicroservice) Run(_workers []string) error {
+	return r.RunWithJobs([]string{}, _workers, []string{})
 }
 
 // Main microservice loop. Runs all prejobs, workers and postjobs. The loop setups
 // adapters, captures the thread and wait for SIGINT or SIGTERM
 // signals. After termination runs postjobs and releases the thread.
-func (r *RadianMicroservice) RunAll() {
-	r.RunWithJobs(r.preJobNames, r.workerNames, r.postJobNames)
+func (r *RadianMicroservice) RunAll() error {
+	return r.RunWithJobs(r.preJobNames, r.workerNames, r.postJobNames)
 }
 
 // Main microservice loop. Use this instead of Run(). The loop
 // setups adapters, runs prejobs, captures the thread and wait
-// for SIGINT or SIGTERM signals. After termination runs
-// postjobs and releases the thread.
-func (r *RadianMicroservice) RunWithJobs(_preJobs []string, _workers []string, _postJobs []string) {
-	logrus.SetFormatter(&logrus.JSONFormatter{})
+// for SIGINT or SIGTERM signals or a worker failure. After
+// termination stops all workers, runs postjobs and releases
+// the thread. Errors of all stages are returned together.
+func (r *RadianMicroservice) RunWithJobs(_preJobs []string, _workers []string, _postJobs []string) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := r.start(ctx, _preJobs, _workers, _postJobs); err != nil {
+		return err
+	}
 
-	r.logger.Info("running")
+	return r.Wait()
+}
 
-	// check prejob names
-	for _, jobName := range _preJobs {
-		if _, ok := r.preJobs[jobName]; !ok {
-			avails := []string{}
-			for k := range r.preJobs {
-				avails = append(avails, k)
-			}
+// Start runs all prejobs, workers and postjobs in background and
+// returns immediately. The microservice is stopped when the context
+// is cancelled, a worker fails or Shutdown() is called. Use Ready()
+// to wait for workers and Wait() to get the result.
+func (r *RadianMicroservice) Start(ctx context.Context) error {
+	return r.start(ctx, r.preJobNames, r.workerNames, r.postJobNames)
+}
 
-			r.logger.Fatalf("prejob with name %s is not found. Available names: %s", jobName, strings.Join(avails, ", "))
-		}
+// Shutdown stops a started microservice, waits for postjobs and
+// returns errors of all stages like Wait(). If the context is done
+// earlier the context error is returned and the microservice keeps
+// stopping in background.
+func (r *RadianMicroservice) Shutdown(ctx context.Context) error {
+	if err := r.state.shutdown(ctx); err != nil && !errors.Is(err, errNotStarted) {
+		return err
 	}
 
-	// check service names
-	for _, serviceName := range _workers {
-		if _, ok := r.workers[serviceName]; !ok {
-			avails := []string{}
-			for k := range r.workers {
-				avails = append(avails, k)
-			}
+	return nil
+}
 
-			r.logger.Fatalf("worker with name %s is not found. Available names: %s", serviceName, strings.Join(avails, ", "))
+// Wait blocks until a started microservice is stopped and returns
+// errors of all stages.
+func (r *RadianMicroservice) Wait() error {
+	if err := r.state.wait(); err != nil {
+		if errors.Is(err, errNotStarted) {
+			return fmt.Errorf("microservice %s is %w", r.GetName(), err)
 		}
+
+		return err
 	}
 
-	// check postjob names
-	for _, jobName := range _postJobs {
-		if _, ok := r.postJobs[jobName]; !ok {
-			avails := []string{}
-			for k := range r.postJobs {
-				avails = append(avails, k)
-			}
+	return nil
+}
 
-			r.logger.Fatalf("postjob with name %s is not found. Available names: %s", jobName, strings.Join(avails, ", "))
-		}
+// Ready returns a channel which is closed when all workers of a
+// started microservice are ready to receive requests or events.
+func (r *RadianMicroservice) Ready() <-chan struct{} {
+	return r.state.readyChan()
+}
+
+// Function checks names and starts the main loop in background.
+func (r *RadianMicroservice) start(ctx context.Context, _preJobs []string, _workers []string, _postJobs []string) error {
+	if err := checkNames("prejob", _preJobs, maps.Keys(r.preJobs)); err != nil {
+		return err
 	}
 
-	// run prejobs
-	for _, jobName := range _preJobs {
-		r.logger.Infof("prejob %s: setting up adapters", jobName)
-		err := r.preJobs[jobName].SetupAdapters()
+	if err := checkNames("worker", _workers, maps.Keys(r.workers)); err != nil {
+		return err
+	}
 
-		if err != nil {
-			r.logger.Fatalf("prejob %s: adapter init error %v", jobName, err)
-		}
+	if err := checkNames("postjob", _postJobs, maps.Keys(r.postJobs)); err != nil {
+		return err
+	}
 
-		r.logger.Infof("prejob %s: running", jobName)
-		err = r.preJobs[jobName].Run(context.TODO())
+	users, adapterNames, err := r.sharedAdapterUsers(_preJobs, _workers, _postJobs)
 
-		if err != nil {
-			r.logger.Fatalf("prejob %s: job run error %v", jobName, err)
-		}
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel, err := r.state.begin(ctx)
+
+	if err != nil {
+		return fmt.Errorf("microservice %s is %w", r.GetName(), err)
+	}
+
+	r.mutex.Lock()
+	r.workerRestarts = make(map[string]bool)
+	r.replacements = make(map[string]worker.WorkerInterface)
+	r.reconfigurations = make(map[string]func(w worker.WorkerInterface) error)
+	r.mutex.Unlock()
 
-		r.logger.Infof("prejob %s: stopping", jobName)
-		r.logger.Infof("prejob %s: deleting adapters", jobName)
-		err = r.preJobs[jobName].CloseAdapters()
+	go func() {
+		err := r.acquireAdapters(users, adapterNames)
 
 		if err != nil {
-			r.logger.Fatalf("prejob %s: adapter close error %v", jobName, err)
+			r.logger.Error(err)
+		} else {
+			r.mutex.Lock()
+			r.acquiredAdapterNames = adapterNames
+			r.mutex.Unlock()
+
+			err = errors.Join(r.run(ctx, cancel, _preJobs, _workers, _postJobs), r.releaseAdapters(adapterNames))
 		}
 
-		r.logger.Infof("prejob %s: completed", jobName)
+		r.state.finish(err)
+	}()
+
+	return nil
+}
+
+// Function runs prejobs and workers with the context. Workers are
+// stopped when the context is cancelled or a worker fails. Postjobs
+// get a fresh context because the root one is already cancelled.
+func (r *RadianMicroservice) run(ctx context.Context, cancel context.CancelFunc, _preJobs []string, _workers []string, _postJobs []string) error {
+	logrus.SetFormatter(&logrus.JSONFormatter{})
+
+	r.logger.Info("running")
+
+	r.mutex.Lock()
+	r.failures = nil
+	r.mutex.Unlock()
+
+	r.draining.Store(false)
+
+	// run prejobs
+	for _, jobName := range _preJobs {
+		if err := r.runJob(ctx, "prejob", r.preJobs[jobName]); err != nil {
+			r.logger.Error(err)
+
+			return err
+		}
 	}
 
 	// run workers
+	r.setRunningWorkerNames(_workers)
+	defer r.setRunningWorkerNames(nil)
+
+	// workers keep serving during the drain phase after the root
+	// context has been cancelled
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	errs := []error{}
+	results := make(chan error, len(_workers))
 	wg := sync.WaitGroup{}
 
 	for _, serviceName := range _workers {
@@ -208,70 +316,233 @@ func (r *RadianMicroservice) RunWithJobs(_preJobs []string, _workers []string, _
 		go func(name string) {
 			defer wg.Done()
 
-			r.logger.Infof("worker %s: setting up adapters", name)
-			err := r.workers[name].SetupAdapters()
-
-			if err != nil {
-				r.logger.Fatalf("worker %s: adapter init error %v", name, err)
+			if err := r.runWorker(workersCtx, name); err != nil {
+				results <- err
 			}
+		}(serviceName)
+	}
 
-			r.logger.Infof("worker %s: setting up worker", name)
-			r.workers[name].Setup() // TODO: return and check error
+	go r.watchReadiness(workersCtx, _workers)
 
-			r.logger.Infof("worker %s: running", name)
-			r.workers[name].Run() // TODO: return and check error
+	select {
+	case <-ctx.Done():
+	case err := <-results:
+		r.logger.Error(err)
 
-			r.logger.Infof("worker %s: stopping", name)
-			r.logger.Infof("worker %s: deleting adapters", name)
-			err = r.workers[name].CloseAdapters()
+		errs = append(errs, err)
+	}
 
-			if err != nil {
-				r.logger.Fatalf("worker %s: adapter close error %v", name, err)
-			}
+	cancel()
 
-			r.logger.Infof("worker %s: stopped", name)
-		}(serviceName)
-	}
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
 
-	done := make(chan os.Signal, 1)
-	signal.Notify(done, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	if err := r.shutdown(stopWorkers, _workers, done); err != nil {
+		r.logger.Error(err)
 
-	<-done
+		errs = append(errs, err)
+	}
 
-	r.logger.Info("stopping workers")
+	for len(results) > 0 {
+		err := <-results
 
-	for _, serviceName := range _workers {
-		r.workers[serviceName].Stop()
+		r.logger.Error(err)
+
+		errs = append(errs, err)
 	}
 
-	wg.Wait()
+	r.mutex.Lock()
+	errs = append(errs, r.failures...)
+	r.mutex.Unlock()
 
-	// run posttasks
+	// run postjobs
 	for _, jobName := range _postJobs {
-		r.logger.Infof("postjob %s: setting up adapters", jobName)
-		err := r.postJobs[jobName].SetupAdapters()
+		if err := r.runJob(context.Background(), "postjob", r.postJobs[jobName]); err != nil {
+			r.logger.Error(err)
 
-		if err != nil {
-			r.logger.Fatalf("postjob %s: adapter init error %v", jobName, err)
+			errs = append(errs, err)
 		}
+	}
 
-		r.logger.Infof("postjob %s: running", jobName)
-		err = r.postJobs[jobName].Run(context.TODO())
+	r.logger.Info("stopped")
 
-		if err != nil {
-			r.logger.Fatalf("postjob %s: job run error %v", jobName, err)
+	return errors.Join(errs...)
+}
+
+// Function sets names of workers run by the microservice, health
+// checks and restarts see only these workers.
+func (r *RadianMicroservice) setRunningWorkerNames(names []string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.runningWorkerNames = names
+}
+
+// Function returns a copy of names of running workers.
+func (r *RadianMicroservice) getRunningWorkerNames() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return slices.Clone(r.runningWorkerNames)
+}
+
+// Function notifies Ready() waiters when all workers are ready.
+func (r *RadianMicroservice) watchReadiness(ctx context.Context, _workers []string) {
+	ticker := time.NewTicker(ReadinessPollInterval)
+	defer ticker.Stop()
+
+	for {
+		ready := true
+
+		for _, name := range _workers {
+			ready = ready && r.getWorker(name).IsReady()
+		}
+
+		if ready {
+			r.logger.Info("ready")
+			r.state.markReady()
+
+			return
 		}
 
-		r.logger.Infof("postjob %s: stopping", jobName)
-		r.logger.Infof("postjob %s: deleting adapters", jobName)
-		err = r.postJobs[jobName].CloseAdapters()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Function setups adapters, runs and finally deletes adapters
+// of a prejob or a postjob.
+func (r *RadianMicroservice) runJob(ctx context.Context, kind string, j *job.TaskJob) (err error) {
+	name := j.GetName()
+
+	r.logger.Infof("%s %s: setting up adapters", kind, name)
+
+	if err = j.SetupAdapters(); err != nil {
+		return fmt.Errorf("%s %s: adapter init error %w", kind, name, err)
+	}
+
+	r.logger.Infof("%s %s: running", kind, name)
+
+	if err = j.Run(ctx); err != nil {
+		err = fmt.Errorf("%s %s: job run error %w", kind, name, err)
+	}
+
+	r.logger.Infof("%s %s: stopping", kind, name)
+	r.logger.Infof("%s %s: deleting adapters", kind, name)
+
+	if closeErr := j.CloseAdapters(); closeErr != nil {
+		err = errors.Join(err, fmt.Errorf("%s %s: adapter close error %w", kind, name, closeErr))
+	}
+
+	if err == nil {
+		r.logger.Infof("%s %s: completed", kind, name)
+	}
+
+	return
+}
+
+// Function runs the worker until the context is cancelled or the
+// worker is stopped. A worker stopped by RestartWorker() or
+// ReplaceWorker() is run again without the restart policy.
+func (r *RadianMicroservice) runWorker(ctx context.Context, name string) error {
+	for {
+		err := r.runWorkerInstance(ctx, name)
+
+		if ctx.Err() != nil || !r.takeRestart(name) {
+			return err
+		}
 
 		if err != nil {
-			r.logger.Fatalf("postjob %s: adapter close error %v", jobName, err)
+			r.logger.Error(err)
 		}
 
-		r.logger.Infof("postjob %s: completed", jobName)
+		r.logger.Infof("worker %s: restarting on request", name)
 	}
+}
 
-	r.logger.Info("stopped")
+// Function setups adapters, runs the worker under supervision
+// and deletes adapters after the worker has been stopped.
+func (r *RadianMicroservice) runWorkerInstance(ctx context.Context, name string) (err error) {
+	w := r.getWorker(name)
+
+	r.logger.Infof("worker %s: setting up adapters", name)
+
+	if err = w.SetupAdapters(); err != nil {
+		return fmt.Errorf("worker %s: adapter init error %w", name, err)
+	}
+
+	defer func() {
+		r.logger.Infof("worker %s: deleting adapters", name)
+
+		if closeErr := w.CloseAdapters(); closeErr != nil {
+			err = errors.Join(err, fmt.Errorf("worker %s: adapter close error %w", name, closeErr))
+		}
+
+		r.logger.Infof("worker %s: stopped", name)
+	}()
+
+	return r.superviseWorker(ctx, name)
+}
+
+// Function setups and runs the worker once. The worker is not
+// run if the context is already cancelled. Panics are returned
+// as errors.
+func (r *RadianMicroservice) runWorkerOnce(ctx context.Context, name string) (err error) {
+	w := r.getWorker(name)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	r.setWorkerCancel(name, cancel)
+	defer r.setWorkerCancel(name, nil)
+
+	r.logger.Infof("worker %s: setting up worker", name)
+
+	if err = safeCall(w.Setup); err != nil {
+		return fmt.Errorf("worker %s: setup error %w", name, err)
+	}
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	r.logger.Infof("worker %s: running", name)
+
+	if err = safeCall(func() error { return w.Run(ctx) }); err != nil {
+		return fmt.Errorf("worker %s: run error %w", name, err)
+	}
+
+	r.logger.Infof("worker %s: stopping", name)
+
+	return
+}
+
+// Function saves an error of a worker which has failed without
+// stopping the microservice.
+func (r *RadianMicroservice) addFailure(err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.failures = append(r.failures, err)
+}
+
+// Function returns an error if any name is not in the list of
+// available names.
+func checkNames(kind string, names []string, avails []string) error {
+	for _, name := range names {
+		if !slices.Contains(avails, name) {
+			slices.Sort(avails)
+
+			return fmt.Errorf("%s with name %s is not found. Available names: %s", kind, name, strings.Join(avails, ", "))
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,108 @@
+package framework
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/radianteam/framework/adapter"
+	"github.com/radianteam/framework/worker"
+)
+
+// AddAdapter registers a shared adapter of the microservice.
+// Workers and jobs request it by name with UseAdapter(). The
+// adapter is set up once before prejobs and closed after
+// postjobs. If an adapter with the same name is already
+// registered an error will be thrown.
+func (r *RadianMicroservice) AddAdapter(adap adapter.AdapterInterface) error {
+	adap.SetLogger(r.logger.WithField("adapter", adap.GetName()))
+
+	return r.GetAdapterPool().AddAdapter(adap)
+}
+
+// Function returns the pool of shared adapters.
+func (r *RadianMicroservice) GetAdapterPool() *worker.AdapterPool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.adapters == nil {
+		r.adapters = worker.NewAdapterPool()
+	}
+
+	return r.adapters
+}
+
+// Function returns workers and jobs which will be run and names
+// of shared adapters they use. An error is returned if an adapter
+// is not found in the pool.
+func (r *RadianMicroservice) sharedAdapterUsers(_preJobs []string, _workers []string, _postJobs []string) ([]worker.WorkerInterface, []string, error) {
+	users := []worker.WorkerInterface{}
+
+	for _, name := range _preJobs {
+		users = append(users, r.preJobs[name])
+	}
+
+	for _, name := range _workers {
+		users = append(users, r.workers[name])
+	}
+
+	for _, name := range _postJobs {
+		users = append(users, r.postJobs[name])
+	}
+
+	pool := r.GetAdapterPool()
+	names := []string{}
+
+	for _, user := range users {
+		for _, name := range user.GetUsedAdapterNames() {
+			if _, err := pool.Get(name); err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", user.GetName(), err)
+			}
+
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return users, names, nil
+}
+
+// Function sets up shared adapters with their dependencies and
+// attaches them to workers and jobs.
+func (r *RadianMicroservice) acquireAdapters(users []worker.WorkerInterface, names []string) error {
+	pool := r.GetAdapterPool()
+
+	r.logger.Infof("setting up adapters: %s", strings.Join(names, ", "))
+
+	if err := pool.AcquireAll(names); err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		for _, name := range user.GetUsedAdapterNames() {
+			adap, _ := pool.Get(name)
+
+			user.AttachAdapter(adap)
+		}
+	}
+
+	return nil
+}
+
+// Function releases shared adapters in the reverse order.
+func (r *RadianMicroservice) releaseAdapters(names []string) error {
+	r.logger.Infof("releasing adapters: %s", strings.Join(names, ", "))
+
+	return r.GetAdapterPool().ReleaseAll(names)
+}
+
+// AddAdapter registers an adapter shared by all microservices run
+// by the manager. In the monolith mode it is set up once for all
+// of them. Microservice adapters with the same name take precedence.
+func (rsm *RadianServiceManager) AddAdapter(adap adapter.AdapterInterface) error {
+	adap.SetLogger(rsm.logger.WithField("adapter", adap.GetName()))
+
+	return rsm.adapters.AddAdapter(adap)
+}
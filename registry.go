@@ -0,0 +1,571 @@
+package framework
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/exp/slices"
+
+	"github.com/radianteam/framework/adapter"
+	"github.com/radianteam/framework/adapter/auth/oidc"
+	"github.com/radianteam/framework/adapter/event/rabbitmq"
+	"github.com/radianteam/framework/adapter/event/sqs"
+	"github.com/radianteam/framework/adapter/storage/arangodb"
+	"github.com/radianteam/framework/adapter/storage/mongodb"
+	"github.com/radianteam/framework/adapter/storage/s3"
+	"github.com/radianteam/framework/adapter/storage/sqlx"
+	"github.com/radianteam/framework/adapter/util/config"
+	"github.com/radianteam/framework/worker"
+	rabbitmq_worker "github.com/radianteam/framework/worker/event/rabbitmq"
+	sqs_worker "github.com/radianteam/framework/worker/event/sqs"
+	"github.com/radianteam/framework/worker/service/grpc"
+	"github.com/radianteam/framework/worker/service/rest"
+	"github.com/radianteam/framework/worker/task/schedule"
+)
+
+const (
+	ConfigAdaptersSection    = "Adapters"
+	ConfigWorkersSection     = "Workers"
+	ConfigTypeKey            = "Type"
+	ConfigEnableKey          = "Enable"
+	ConfigMonitoringKey      = "Monitoring"
+	ConfigWorkerAdaptersKey  = "Adapters"
+	ConfigDependsOnKey       = "DependsOn"
+	ConfigShutdownKey        = "Shutdown"
+	ConfigRestartOnChangeKey = "RestartOnChange"
+)
+
+type AdapterCreatorFunc func(name string, configAdapter *config.ConfigAdapter) (adapter.AdapterInterface, error)
+
+type WorkerCreatorFunc func(name string, configAdapter *config.ConfigAdapter) (worker.WorkerInterface, error)
+
+// Interface of workers which can apply a changed configuration
+// section keeping their routes and handlers. Workers which do not
+// implement it are created again when "RestartOnChange" is set.
+// ApplyConfig is called while the worker is stopped, a running
+// worker returns worker.ErrWorkerRunning.
+type ReconfigurableWorkerInterface interface {
+	ApplyConfig(configAdapter *config.ConfigAdapter) error
+}
+
+// Function is called after a microservice has been built from
+// the configuration. Use it to set routes, events and tasks.
+type MicroserviceSetupFunc func(ms *RadianMicroservice) error
+
+// Registry structure holds adapter and worker creators by their
+// configuration type names.
+type RadianRegistry struct {
+	adapterCreators map[string]AdapterCreatorFunc
+	workerCreators  map[string]WorkerCreatorFunc
+
+	// configuration structures of types for the JSON Schema
+	adapterConfigTypes map[string]any
+	workerConfigTypes  map[string]any
+}
+
+// Function allocates a registry with all adapter and worker
+// types provided by the framework.
+func NewRadianRegistry() *RadianRegistry {
+	return &RadianRegistry{
+		adapterCreators: map[string]AdapterCreatorFunc{
+			"sqlx":     sqlx.SqlxAdapterCreate,
+			"mongodb":  mongodb.MongoDbAdapterCreate,
+			"arangodb": arangodb.ArangoDbAdapterCreate,
+			"rabbitmq": rabbitmq.RabbitMqAdapterCreate,
+			"sqs":      sqs.AwsSqsAdapterCreate,
+			"s3":       s3.AwsS3AdapterCreate,
+			"oidc":     oidc.OidcAdapterCreate,
+		},
+		workerCreators: map[string]WorkerCreatorFunc{
+			"rest":     rest.RestServiceWorkerCreate,
+			"grpc":     grpc.GrpcServiceWorkerCreate,
+			"schedule": schedule.TaskScheduleCreate,
+			"rabbitmq": rabbitmq_worker.RabbitMqEventWorkerCreate,
+			"sqs":      sqs_worker.AwsSqsEventsWorkerCreate,
+		},
+		adapterConfigTypes: map[string]any{
+			"sqlx":     sqlx.SqlxConfig{},
+			"mongodb":  mongodb.MongoDbConfig{},
+			"arangodb": arangodb.ArangoDbConfig{},
+			"rabbitmq": rabbitmq.RabbitMqConfig{},
+			"sqs":      sqs.AwsSqsConfig{},
+			"s3":       s3.AwsS3Config{},
+			"oidc":     oidc.OidcConfig{},
+		},
+		workerConfigTypes: map[string]any{
+			"rest":     rest.RestConfig{},
+			"grpc":     grpc.GrpcConfig{},
+			"schedule": struct{}{}, // the scheduler has no configuration
+			"rabbitmq": rabbitmq_worker.RabbitMqConfig{},
+			"sqs":      sqs_worker.AwsSqsWorkerConfig{},
+		},
+	}
+}
+
+// AddAdapterType registers an adapter creator by a type name.
+// If the type is already registered an error will be thrown.
+func (r *RadianRegistry) AddAdapterType(typeName string, creator AdapterCreatorFunc) error {
+	if _, ok := r.adapterCreators[typeName]; ok {
+		return fmt.Errorf("adapter type %s has been already registered", typeName)
+	}
+
+	r.adapterCreators[typeName] = creator
+
+	return nil
+}
+
+// AddWorkerType registers a worker creator by a type name.
+// If the type is already registered an error will be thrown.
+func (r *RadianRegistry) AddWorkerType(typeName string, creator WorkerCreatorFunc) error {
+	if _, ok := r.workerCreators[typeName]; ok {
+		return fmt.Errorf("worker type %s has been already registered", typeName)
+	}
+
+	r.workerCreators[typeName] = creator
+
+	return nil
+}
+
+// SetAdapterConfigType sets the configuration structure of an
+// adapter type. It is used to build the JSON Schema of the
+// configuration.
+func (r *RadianRegistry) SetAdapterConfigType(typeName string, configStruct any) error {
+	if _, ok := r.adapterCreators[typeName]; !ok {
+		return fmt.Errorf("adapter type %s is not registered", typeName)
+	}
+
+	r.adapterConfigTypes[typeName] = configStruct
+
+	return nil
+}
+
+// SetWorkerConfigType sets the configuration structure of a worker
+// type. It is used to build the JSON Schema of the configuration.
+func (r *RadianRegistry) SetWorkerConfigType(typeName string, configStruct any) error {
+	if _, ok := r.workerCreators[typeName]; !ok {
+		return fmt.Errorf("worker type %s is not registered", typeName)
+	}
+
+	r.workerConfigTypes[typeName] = configStruct
+
+	return nil
+}
+
+// Function creates an adapter from a configuration section
+// with the "Type" key.
+func (r *RadianRegistry) CreateAdapter(name string, configAdapter *config.ConfigAdapter) (adapter.AdapterInterface, error) {
+	typeName, err := configAdapter.GetString(ConfigTypeKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("adapter %s has no type: %v", name, err)
+	}
+
+	creator, ok := r.adapterCreators[typeName]
+
+	if !ok {
+		return nil, fmt.Errorf("adapter type %s is not registered. Available types: %s", typeName, sortedNames(r.adapterCreators))
+	}
+
+	return creator(name, configAdapter)
+}
+
+// Function creates a worker from a configuration section
+// with the "Type" key.
+func (r *RadianRegistry) CreateWorker(name string, configAdapter *config.ConfigAdapter) (worker.WorkerInterface, error) {
+	typeName, err := configAdapter.GetString(ConfigTypeKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("worker %s has no type: %v", name, err)
+	}
+
+	creator, ok := r.workerCreators[typeName]
+
+	if !ok {
+		return nil, fmt.Errorf("worker type %s is not registered. Available types: %s", typeName, sortedNames(r.workerCreators))
+	}
+
+	return creator(name, configAdapter)
+}
+
+// Function builds a microservice from the configuration section
+// containing "Adapters" and "Workers". Enabled adapters are shared
+// by the microservice. Every enabled worker uses adapters listed in
+// its "Adapters" key or all enabled adapters if the key is omitted.
+func (r *RadianRegistry) CreateMicroservice(name string, configAdapter *config.ConfigAdapter) (*RadianMicroservice, error) {
+	if configAdapter == nil {
+		return nil, fmt.Errorf("configuration for the microservice %s is not provided", name)
+	}
+
+	workersConfig, err := configAdapter.GetAdapter(ConfigWorkersSection)
+
+	if err != nil {
+		return nil, fmt.Errorf("microservice %s has no workers section: %v", name, err)
+	}
+
+	adaptersConfig := configAdapter.GetAdapterOrNil(ConfigAdaptersSection)
+
+	adapterNames := []string{}
+	errs := []error{}
+
+	if adaptersConfig != nil {
+		for _, adapterName := range sortedKeys(adaptersConfig) {
+			adapterConfig, err := adaptersConfig.GetAdapter(adapterName)
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("adapter %s has a wrong configuration: %v", adapterName, err))
+
+				continue
+			}
+
+			enabled, err := isSectionEnabled(adapterConfig)
+
+			if err != nil {
+				errs = append(errs, fmt.Errorf("adapter %s: %v", adapterName, err))
+
+				continue
+			}
+
+			if enabled {
+				adapterNames = append(adapterNames, adapterName)
+			}
+		}
+	}
+
+	ms := NewRadianMicroservice(name)
+
+	if configAdapter.Has(ConfigShutdownKey) {
+		cfg := ShutdownConfig{}
+
+		if err = configAdapter.UnmarshalPath(&cfg, false, ConfigShutdownKey); err == nil {
+			err = ms.SetShutdownConfig(cfg)
+		}
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %v", ConfigShutdownKey, err))
+		}
+	}
+
+	for _, adapterName := range adapterNames {
+		adap, err := r.CreateAdapter(adapterName, adaptersConfig.GetAdapterOrNil(adapterName))
+
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		dependencies, err := getNameList(adaptersConfig.GetAdapterOrNil(adapterName), ConfigDependsOnKey, adapterNames)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("adapter %s: %v", adapterName, err))
+
+			continue
+		}
+
+		if len(dependencies) > 0 {
+			dependent, ok := adap.(interface{ DependsOn(names ...string) })
+
+			if !ok {
+				errs = append(errs, fmt.Errorf("adapter %s cannot have dependencies", adapterName))
+
+				continue
+			}
+
+			dependent.DependsOn(dependencies...)
+		}
+
+		if err = ms.AddAdapter(adap); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	subscriptions := []func(){}
+
+	for _, workerName := range sortedKeys(workersConfig) {
+		workerConfig, err := workersConfig.GetAdapter(workerName)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("worker %s has a wrong configuration: %v", workerName, err))
+
+			continue
+		}
+
+		enabled, err := isSectionEnabled(workerConfig)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("worker %s: %v", workerName, err))
+
+			continue
+		}
+
+		if !enabled {
+			continue
+		}
+
+		w, err := r.createConfiguredWorker(workerName, workerConfig, adapterNames)
+
+		if err != nil {
+			errs = append(errs, err)
+
+			continue
+		}
+
+		restart, err := getBool(workerConfig, false, ConfigRestartOnChangeKey)
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("worker %s: %v", workerName, err))
+
+			continue
+		}
+
+		if restart {
+			subscriber := r.restartOnChange(ms, workerName, adapterNames)
+			workerName := workerName
+
+			subscriptions = append(subscriptions, func() {
+				workersConfig.Subscribe(subscriber, workerName)
+			})
+		}
+
+		if err = ms.AddWorker(w); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	for _, subscribe := range subscriptions {
+		subscribe()
+	}
+
+	return ms, nil
+}
+
+// MicroserviceJsonSchema returns the JSON Schema of a microservice
+// section. Sections of adapters and workers are described by the
+// configuration structures of their types.
+func (r *RadianRegistry) MicroserviceJsonSchema() map[string]any {
+	adapterSchema := typedSectionSchema(maps.Keys(r.adapterCreators), r.adapterConfigTypes, map[string]any{
+		ConfigEnableKey:    map[string]any{"type": "boolean", "default": true},
+		ConfigDependsOnKey: nameListSchema(),
+	})
+
+	workerSchema := typedSectionSchema(maps.Keys(r.workerCreators), r.workerConfigTypes, map[string]any{
+		ConfigEnableKey:          map[string]any{"type": "boolean", "default": true},
+		ConfigMonitoringKey:      map[string]any{"type": "boolean", "default": false},
+		ConfigWorkerAdaptersKey:  nameListSchema(),
+		ConfigRestartOnChangeKey: map[string]any{"type": "boolean", "default": false},
+	})
+
+	return map[string]any{
+		"type":     "object",
+		"required": []string{ConfigWorkersSection},
+		"properties": map[string]any{
+			ConfigAdaptersSection: map[string]any{"type": "object", "additionalProperties": adapterSchema},
+			ConfigWorkersSection:  map[string]any{"type": "object", "additionalProperties": workerSchema},
+			ConfigShutdownKey:     config.JsonSchema(ShutdownConfig{}),
+		},
+	}
+}
+
+// Function builds the schema of an adapter or a worker section. The
+// configuration structure is selected by the "Type" key.
+func typedSectionSchema(typeNames []string, configTypes map[string]any, common map[string]any) map[string]any {
+	slices.Sort(typeNames)
+
+	conditions := []any{}
+
+	for _, typeName := range typeNames {
+		configStruct, ok := configTypes[typeName]
+
+		if !ok {
+			continue
+		}
+
+		conditions = append(conditions, map[string]any{
+			"if":   map[string]any{"properties": map[string]any{ConfigTypeKey: map[string]any{"const": typeName}}},
+			"then": config.JsonSchema(configStruct),
+		})
+	}
+
+	common[ConfigTypeKey] = map[string]any{"type": "string", "enum": typeNames}
+
+	schema := map[string]any{
+		"type":       "object",
+		"required":   []string{ConfigTypeKey},
+		"properties": common,
+	}
+
+	if len(conditions) > 0 {
+		schema["allOf"] = conditions
+	}
+
+	return schema
+}
+
+// Function returns the schema of a list of names which can be set
+// by a comma separated string too.
+func nameListSchema() map[string]any {
+	return map[string]any{"type": []string{"array", "string"}, "items": map[string]any{"type": "string"}}
+}
+
+// Function creates a worker and applies the "Monitoring" and
+// "Adapters" keys of its section.
+func (r *RadianRegistry) createConfiguredWorker(workerName string, workerConfig *config.ConfigAdapter, adapterNames []string) (worker.WorkerInterface, error) {
+	w, err := r.CreateWorker(workerName, workerConfig)
+
+	if err != nil {
+		return nil, err
+	}
+
+	monitoring, err := getBool(workerConfig, false, ConfigMonitoringKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("worker %s: %v", workerName, err)
+	}
+
+	w.SetMonitoring(monitoring)
+
+	workerAdapterNames := adapterNames
+
+	if workerConfig.Has(ConfigWorkerAdaptersKey) {
+		workerAdapterNames, err = getNameList(workerConfig, ConfigWorkerAdaptersKey, adapterNames)
+
+		if err != nil {
+			return nil, fmt.Errorf("worker %s: %v", workerName, err)
+		}
+	}
+
+	w.UseAdapter(workerAdapterNames...)
+
+	return w, nil
+}
+
+// Function returns a configuration subscriber which restarts the
+// worker when its section is changed. Reconfigurable workers apply
+// the new section, other workers are replaced by new instances.
+// Errors are logged and the worker keeps the old configuration.
+func (r *RadianRegistry) restartOnChange(ms *RadianMicroservice, workerName string, adapterNames []string) config.ConfigSubscriberFunc {
+	return func(section *config.ConfigAdapter, changes []config.ConfigChange) {
+		if section == nil {
+			ms.logger.Errorf("worker %s: configuration section is removed, the worker is not changed", workerName)
+
+			return
+		}
+
+		w, err := ms.GetWorker(workerName)
+
+		if err != nil {
+			ms.logger.Error(err)
+
+			return
+		}
+
+		paths := []string{}
+
+		for _, change := range changes {
+			paths = append(paths, change.String())
+		}
+
+		ms.logger.Infof("worker %s: configuration is changed (%s), restarting", workerName, strings.Join(paths, ", "))
+
+		if _, ok := w.(ReconfigurableWorkerInterface); ok {
+			monitoring, err := getBool(section, false, ConfigMonitoringKey)
+
+			if err == nil {
+				err = ms.ReconfigureWorker(workerName, func(w worker.WorkerInterface) error {
+					w.SetMonitoring(monitoring)
+
+					return w.(ReconfigurableWorkerInterface).ApplyConfig(section)
+				})
+			}
+
+			if err != nil {
+				ms.logger.Errorf("worker %s: cannot apply the changed configuration - %s", workerName, err)
+			}
+
+			return
+		}
+
+		w, err = r.createConfiguredWorker(workerName, section, adapterNames)
+
+		if err == nil {
+			err = ms.ReplaceWorker(w)
+		}
+
+		if err != nil {
+			ms.logger.Errorf("worker %s: cannot apply the changed configuration - %s", workerName, err)
+		}
+	}
+}
+
+// Function checks whether a configuration section has the
+// "Workers" section and can be built by the registry.
+func isDeclaredMicroservice(configAdapter *config.ConfigAdapter) bool {
+	if configAdapter == nil {
+		return false
+	}
+
+	_, err := configAdapter.GetAdapter(ConfigWorkersSection)
+
+	return err == nil
+}
+
+// Function reads the "Enable" key of a section. Sections
+// without the key are enabled.
+func isSectionEnabled(configAdapter *config.ConfigAdapter) (bool, error) {
+	return getBool(configAdapter, true, ConfigEnableKey)
+}
+
+func getBool(configAdapter *config.ConfigAdapter, defaultValue bool, path ...string) (bool, error) {
+	if !configAdapter.Has(path...) {
+		return defaultValue, nil
+	}
+
+	value, err := configAdapter.GetBool(path...)
+
+	if err != nil {
+		return false, fmt.Errorf("key %s must be a boolean", path[len(path)-1])
+	}
+
+	return value, nil
+}
+
+// Function reads a list of adapter names. Every name must be
+// in the list of enabled adapters. A missing key is an empty list.
+func getNameList(configAdapter *config.ConfigAdapter, key string, avails []string) ([]string, error) {
+	if !configAdapter.Has(key) {
+		return []string{}, nil
+	}
+
+	names, err := configAdapter.GetStringSlice(key)
+
+	if err != nil {
+		return nil, fmt.Errorf("key %s must be a list of adapter names", key)
+	}
+
+	for _, name := range names {
+		if !slices.Contains(avails, name) {
+			return nil, fmt.Errorf("adapter %s is not found or disabled", name)
+		}
+	}
+
+	return names, nil
+}
+
+func sortedKeys(configAdapter *config.ConfigAdapter) []string {
+	keys, _ := configAdapter.Keys()
+
+	return keys
+}
+
+func sortedNames[T any](m map[string]T) string {
+	keys := maps.Keys(m)
+	slices.Sort(keys)
+
+	return strings.Join(keys, ", ")
+}
@@ -0,0 +1,187 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/exp/slices"
+
+	"github.com/radianteam/framework/worker"
+)
+
+// RestartWorker gracefully stops a running worker and runs it
+// again. Adapters of the worker are set up again, the restart
+// policy is not applied.
+func (r *RadianMicroservice) RestartWorker(name string) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := r.workers[name]; !ok {
+		return fmt.Errorf("worker with name %s is not found", name)
+	}
+
+	if !r.isWorkerRunning(name) {
+		return fmt.Errorf("worker %s of microservice %s is not running", name, r.GetName())
+	}
+
+	r.requestRestart(name)
+
+	return nil
+}
+
+// ReplaceWorker replaces a registered worker with a new instance
+// of the same name, e.g. created from a changed configuration. A
+// running worker is gracefully stopped and the new one is started.
+// Shared adapters of the new worker must be already set up.
+func (r *RadianMicroservice) ReplaceWorker(w worker.WorkerInterface) error {
+	name := w.GetName()
+	pool := r.GetAdapterPool()
+
+	if _, err := r.GetWorker(name); err != nil {
+		return err
+	}
+
+	w.SetMicroserviceName(r.GetName())
+
+	if consumer, ok := w.(worker.HealthConsumerInterface); ok {
+		consumer.SetHealthProvider(r)
+	}
+
+	for _, adapterName := range w.GetUsedAdapterNames() {
+		adap, err := pool.Get(adapterName)
+
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+
+		w.AttachAdapter(adap)
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if !r.isWorkerRunning(name) {
+		r.workers[name] = w
+
+		return nil
+	}
+
+	for _, adapterName := range w.GetUsedAdapterNames() {
+		if !slices.Contains(r.acquiredAdapterNames, adapterName) {
+			return fmt.Errorf("%s: adapter %s is not set up. Restart the microservice", name, adapterName)
+		}
+	}
+
+	r.replacements[name] = w
+	r.requestRestart(name)
+
+	return nil
+}
+
+// ReconfigureWorker restarts a running worker and calls apply
+// after the worker has been stopped and before it is run again.
+// Use it to change the configuration of a worker keeping its
+// routes and handlers. A worker which is not running is changed
+// immediately.
+func (r *RadianMicroservice) ReconfigureWorker(name string, apply func(w worker.WorkerInterface) error) error {
+	r.mutex.Lock()
+
+	w, ok := r.workers[name]
+
+	if !ok {
+		r.mutex.Unlock()
+
+		return fmt.Errorf("worker with name %s is not found", name)
+	}
+
+	if !r.isWorkerRunning(name) {
+		r.mutex.Unlock()
+
+		return apply(w)
+	}
+
+	r.reconfigurations[name] = apply
+	r.requestRestart(name)
+	r.mutex.Unlock()
+
+	return nil
+}
+
+// Function checks whether the worker is run by the microservice.
+// The mutex must be locked.
+func (r *RadianMicroservice) isWorkerRunning(name string) bool {
+	return r.state.isRunning() && slices.Contains(r.runningWorkerNames, name)
+}
+
+// Function flags the restart of a running worker and stops it. A
+// worker between two runs picks the flag up when it exits. The
+// mutex must be locked.
+func (r *RadianMicroservice) requestRestart(name string) {
+	r.workerRestarts[name] = true
+
+	if cancel := r.workerCancels[name]; cancel != nil {
+		cancel()
+	}
+}
+
+func (r *RadianMicroservice) getWorker(name string) worker.WorkerInterface {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.workers[name]
+}
+
+func (r *RadianMicroservice) setWorkerCancel(name string, cancel context.CancelFunc) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.workerCancels == nil {
+		r.workerCancels = make(map[string]context.CancelFunc)
+	}
+
+	r.workerCancels[name] = cancel
+}
+
+func (r *RadianMicroservice) isRestartRequested(name string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return r.workerRestarts[name]
+}
+
+// Function clears a restart request of a worker, swaps the worker
+// with its replacement and applies the pending configuration. The
+// configuration is applied without the mutex, so health checks are
+// not blocked. It returns false if the restart has not been
+// requested.
+func (r *RadianMicroservice) takeRestart(name string) bool {
+	r.mutex.Lock()
+
+	if !r.workerRestarts[name] {
+		r.mutex.Unlock()
+
+		return false
+	}
+
+	delete(r.workerRestarts, name)
+
+	if replacement, ok := r.replacements[name]; ok {
+		r.workers[name] = replacement
+
+		delete(r.replacements, name)
+	}
+
+	w := r.workers[name]
+	apply, ok := r.reconfigurations[name]
+
+	delete(r.reconfigurations, name)
+	r.mutex.Unlock()
+
+	if ok {
+		if err := apply(w); err != nil {
+			r.logger.Errorf("worker %s: cannot apply the configuration - %s", name, err)
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,148 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	DefaultShutdownTimeout      = 30 * time.Second
+	DefaultShutdownForceTimeout = 5 * time.Second
+)
+
+// Structure describes the shutdown sequence of a microservice:
+//  1. the microservice is marked as not ready;
+//  2. workers keep serving for DrainDelay so load balancers can
+//     notice the readiness change;
+//  3. workers stop accepting new requests and events and in-flight
+//     ones are awaited for Timeout;
+//  4. workers which are still running are force stopped: in-flight
+//     handler contexts are cancelled and connections are closed.
+//     Workers are awaited for ForceTimeout after that.
+type ShutdownConfig struct {
+	DrainDelay   time.Duration `json:"DrainDelay,omitempty" config:"DrainDelay,min=0s"`
+	Timeout      time.Duration `json:"Timeout,omitempty" config:"Timeout,min=0s"`
+	ForceTimeout time.Duration `json:"ForceTimeout,omitempty" config:"ForceTimeout,min=0s"`
+}
+
+// Shutdown configuration used for microservices without a custom one.
+var DefaultShutdownConfig = ShutdownConfig{Timeout: DefaultShutdownTimeout, ForceTimeout: DefaultShutdownForceTimeout}
+
+var metricShutdownPhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "microservice_shutdown_phase_duration_seconds",
+	Help:    "Duration of microservice shutdown phases",
+	Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60},
+}, []string{"microservice", "phase"})
+
+var metricShutdownForced = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "microservice_shutdown_forced_total",
+	Help: "Total shutdowns which required force stopping workers",
+}, []string{"microservice"})
+
+// SetShutdownConfig sets the shutdown sequence of the microservice.
+// Zero timeouts are replaced with default values.
+func (r *RadianMicroservice) SetShutdownConfig(cfg ShutdownConfig) error {
+	if cfg.DrainDelay < 0 || cfg.Timeout < 0 || cfg.ForceTimeout < 0 {
+		return fmt.Errorf("shutdown durations cannot be negative")
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultShutdownTimeout
+	}
+
+	if cfg.ForceTimeout == 0 {
+		cfg.ForceTimeout = DefaultShutdownForceTimeout
+	}
+
+	r.shutdownConfig = &cfg
+
+	return nil
+}
+
+// Function returns the shutdown configuration of the microservice.
+func (r *RadianMicroservice) GetShutdownConfig() ShutdownConfig {
+	if r.shutdownConfig != nil {
+		return *r.shutdownConfig
+	}
+
+	return DefaultShutdownConfig
+}
+
+// Function runs the shutdown sequence. The done channel is closed
+// when all workers have returned. An error is returned if workers
+// are still running after the force stop.
+func (r *RadianMicroservice) shutdown(stopWorkers context.CancelFunc, _workers []string, done <-chan struct{}) error {
+	cfg := r.GetShutdownConfig()
+
+	for _, c := range []prometheus.Collector{metricShutdownPhaseDuration, metricShutdownForced} {
+		if err := registerCollector(c); err != nil {
+			r.logger.Errorf("cannot register shutdown metrics - %s", err)
+		}
+	}
+
+	r.logger.Info("shutdown: marking not ready")
+
+	r.draining.Store(true)
+
+	r.shutdownPhase("drain", func() bool {
+		return waitTimeout(done, cfg.DrainDelay)
+	})
+
+	stopped := r.shutdownPhase("stop", func() bool {
+		stopWorkers()
+
+		return waitTimeout(done, cfg.Timeout)
+	})
+
+	if stopped {
+		return nil
+	}
+
+	metricShutdownForced.With(prometheus.Labels{"microservice": r.GetName()}).Inc()
+
+	stopped = r.shutdownPhase("force", func() bool {
+		for _, name := range _workers {
+			r.getWorker(name).ForceStop()
+		}
+
+		return waitTimeout(done, cfg.ForceTimeout)
+	})
+
+	if !stopped {
+		return fmt.Errorf("workers have not been stopped in %s after the force stop", cfg.ForceTimeout)
+	}
+
+	return nil
+}
+
+// Function runs a shutdown phase, logs and measures it.
+func (r *RadianMicroservice) shutdownPhase(phase string, f func() bool) bool {
+	r.logger.Infof("shutdown: %s phase started", phase)
+
+	start := time.Now()
+	stopped := f()
+	duration := time.Since(start)
+
+	metricShutdownPhaseDuration.With(prometheus.Labels{"microservice": r.GetName(), "phase": phase}).Observe(duration.Seconds())
+
+	r.logger.Infof("shutdown: %s phase completed in %s", phase, duration)
+
+	return stopped
+}
+
+// Function waits until the channel is closed or the timeout is
+// reached. It returns true if the channel has been closed.
+func waitTimeout(done <-chan struct{}, timeout time.Duration) bool {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
@@ -0,0 +1,181 @@
+package framework
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type RestartMode string
+
+const (
+	RestartNever     RestartMode = "never"
+	RestartOnFailure RestartMode = "on-failure"
+	RestartAlways    RestartMode = "always"
+)
+
+const (
+	DefaultRestartBackoffInitial = time.Second
+	DefaultRestartBackoffMax     = time.Minute
+)
+
+// Structure describes what the microservice does when a worker's
+// Run() returns or panics. Restarts are delayed with an exponential
+// backoff from BackoffInitial to BackoffMax. MaxRestarts limits the
+// total amount of restarts (0 means unlimited). If Escalate is set
+// the whole microservice is stopped when the worker fails and is
+// not restarted anymore.
+type RestartPolicy struct {
+	Mode           RestartMode
+	MaxRestarts    int
+	BackoffInitial time.Duration
+	BackoffMax     time.Duration
+	Escalate       bool
+}
+
+// Policy used for workers without a custom one: a failed worker
+// stops the microservice.
+var DefaultRestartPolicy = RestartPolicy{Mode: RestartNever, Escalate: true}
+
+var metricWorkerRestarts = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "microservice_worker_restarts_total",
+	Help: "Total restarts of microservice workers",
+}, []string{"microservice", "worker"})
+
+// SetRestartPolicy sets a restart policy for a registered worker.
+func (r *RadianMicroservice) SetRestartPolicy(workerName string, policy RestartPolicy) error {
+	if _, ok := r.workers[workerName]; !ok {
+		return fmt.Errorf("worker with name %s is not found", workerName)
+	}
+
+	switch policy.Mode {
+	case RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return fmt.Errorf("unknown restart mode %s", policy.Mode)
+	}
+
+	if policy.MaxRestarts < 0 {
+		return fmt.Errorf("max restarts cannot be negative")
+	}
+
+	if policy.BackoffInitial <= 0 {
+		policy.BackoffInitial = DefaultRestartBackoffInitial
+	}
+
+	if policy.BackoffMax < policy.BackoffInitial {
+		policy.BackoffMax = DefaultRestartBackoffMax
+
+		if policy.BackoffMax < policy.BackoffInitial {
+			policy.BackoffMax = policy.BackoffInitial
+		}
+	}
+
+	if r.restartPolicies == nil {
+		r.restartPolicies = make(map[string]RestartPolicy)
+	}
+
+	r.restartPolicies[workerName] = policy
+
+	return nil
+}
+
+// Function returns the restart policy of a worker.
+func (r *RadianMicroservice) GetRestartPolicy(workerName string) RestartPolicy {
+	if policy, ok := r.restartPolicies[workerName]; ok {
+		return policy
+	}
+
+	return DefaultRestartPolicy
+}
+
+// Function runs the worker and restarts it according to its
+// restart policy. It returns an error only if the failure must
+// stop the microservice. Other failures are logged and saved to
+// be returned after the microservice has been stopped.
+func (r *RadianMicroservice) superviseWorker(ctx context.Context, name string) error {
+	policy := r.GetRestartPolicy(name)
+	backoff := policy.BackoffInitial
+	restarts := 0
+
+	if err := registerCollector(metricWorkerRestarts); err != nil {
+		r.logger.Errorf("cannot register restart metrics - %s", err)
+	}
+
+	for {
+		err := r.runWorkerOnce(ctx, name)
+
+		if ctx.Err() != nil || r.isRestartRequested(name) {
+			return err
+		}
+
+		restart := policy.Mode == RestartAlways || (policy.Mode == RestartOnFailure && err != nil)
+
+		if restart && policy.MaxRestarts > 0 && restarts >= policy.MaxRestarts {
+			r.logger.Errorf("worker %s: max restarts count %d is reached", name, policy.MaxRestarts)
+
+			restart = false
+		}
+
+		if !restart {
+			if err == nil {
+				return nil
+			}
+
+			if policy.Escalate {
+				return err
+			}
+
+			r.logger.Error(err)
+			r.addFailure(err)
+
+			return nil
+		}
+
+		if err != nil {
+			r.logger.Error(err)
+		}
+
+		r.logger.Infof("worker %s: restarting in %s", name, backoff)
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+
+		restarts++
+		metricWorkerRestarts.With(prometheus.Labels{"microservice": r.GetName(), "worker": name}).Inc()
+
+		backoff *= 2
+
+		if backoff > policy.BackoffMax {
+			backoff = policy.BackoffMax
+		}
+	}
+}
+
+// Function calls a worker function and converts a panic into
+// an error.
+func safeCall(f func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+
+	return f()
+}
+
+// Function registers a prometheus collector. Repeated
+// registrations are ignored.
+func registerCollector(c prometheus.Collector) error {
+	if err := prometheus.Register(c); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -1,56 +1,160 @@
 package worker
 
 import (
+	"context"
+	"errors"
 	"fmt"
 
 	"github.com/radianteam/framework/adapter"
+	"golang.org/x/exp/slices"
 )
 
 type AdaptersMap map[string]adapter.AdapterInterface
 
-// Structure holds adapter map.
+// Structure holds adapter map. Own adapters are set up and closed
+// by the worker. Shared adapters are taken from the microservice
+// pool by names and are controlled by the pool.
 type WorkerAdapters struct {
 	adapters AdaptersMap
+	names    []string
+
+	usedNames []string
+	shared    map[string]bool
 }
 
 // Function allocates WorkerAdapters structure with an empty
 // (but not nil!) adapter list.
 func NewWorkerAdapters() *WorkerAdapters {
 	adapters := make(AdaptersMap)
-	return &WorkerAdapters{adapters: adapters}
+	return &WorkerAdapters{adapters: adapters, shared: make(map[string]bool)}
 }
 
 // Function appends an adapter to the worker's adapter list.
 // If the adapter with the same name is already registred the
 // first one will be overwritten by the new one.
 func (w *WorkerAdapters) SetAdapter(adapter adapter.AdapterInterface) {
+	w.addName(adapter.GetName())
+	w.adapters[adapter.GetName()] = adapter
+	delete(w.shared, adapter.GetName())
+}
+
+func (w *WorkerAdapters) addName(name string) {
+	if !slices.Contains(w.names, name) {
+		w.names = append(w.names, name)
+	}
+}
+
+// Function requests shared adapters from the microservice pool
+// by names. They are attached before the worker is started.
+func (w *WorkerAdapters) UseAdapter(names ...string) {
+	for _, name := range names {
+		if !slices.Contains(w.usedNames, name) {
+			w.usedNames = append(w.usedNames, name)
+		}
+	}
+}
+
+// Function returns names of shared adapters requested by
+// UseAdapter().
+func (w *WorkerAdapters) GetUsedAdapterNames() []string {
+	return slices.Clone(w.usedNames)
+}
+
+// Function appends a shared adapter which is already set up
+// by the pool. SetupAdapters() and CloseAdapters() skip it.
+func (w *WorkerAdapters) AttachAdapter(adapter adapter.AdapterInterface) {
+	w.addName(adapter.GetName())
 	w.adapters[adapter.GetName()] = adapter
+	w.shared[adapter.GetName()] = true
 }
 
-// Function setups all adapters and is used in the main
-// framework loop.
-func (w *WorkerAdapters) SetupAdapters() (err error) {
-	for _, element := range w.adapters {
-		err = element.Setup()
-		if err != nil {
-			return
+// Function setups all own adapters and is used in the main
+// framework loop. Adapters are set up after their dependencies,
+// independent ones in parallel. If any adapter fails the adapters
+// which are already set up are closed and all errors are returned.
+func (w *WorkerAdapters) SetupAdapters() error {
+	levels, err := w.sortAdapters()
+
+	if err != nil {
+		return err
+	}
+
+	done, err := adapter.SetupLevels(levels, func(name string) error {
+		adap := w.adapters[name]
+
+		if dependent, ok := adap.(adapter.DependentInterface); ok {
+			for _, dep := range dependent.GetDependencies() {
+				dependent.SetDependency(w.adapters[dep])
+			}
 		}
+
+		if err := adap.Setup(); err != nil {
+			return fmt.Errorf("adapter %s init error %w", name, err)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return errors.Join(err, adapter.CloseReverse(done, w.closeAdapter))
+	}
+
+	return nil
+}
+
+// Function clears all own adapters in the reverse order of the
+// setup and is used in the main framework loop. All adapters are
+// closed even if some of them fail.
+func (w *WorkerAdapters) CloseAdapters() error {
+	levels, err := w.sortAdapters()
+
+	if err != nil {
+		return err
+	}
+
+	return adapter.CloseReverse(flatten(levels), w.closeAdapter)
+}
+
+func (w *WorkerAdapters) closeAdapter(name string) error {
+	if err := w.adapters[name].Close(); err != nil {
+		return fmt.Errorf("adapter %s close error %w", name, err)
+	}
+
+	return nil
+}
+
+func flatten(levels [][]string) []string {
+	names := []string{}
+
+	for _, level := range levels {
+		names = append(names, level...)
 	}
 
-	return
+	return names
 }
 
-// Function clears all adapters and is used in the main
-// framework loop.
-func (w *WorkerAdapters) CloseAdapters() (err error) {
-	for _, adap := range w.adapters {
+// Function groups own adapters by dependency levels. Dependencies
+// must be own or shared adapters of the worker.
+func (w *WorkerAdapters) sortAdapters() ([][]string, error) {
+	names := []string{}
+
+	for _, name := range w.names {
+		if w.shared[name] {
+			continue
+		}
 
-		if err = adap.Close(); err != nil {
-			return
+		for _, dep := range adapter.GetDependencies(w.adapters[name]) {
+			if _, ok := w.adapters[dep]; !ok {
+				return nil, fmt.Errorf("adapter %s depends on the adapter %s which is not found", name, dep)
+			}
 		}
+
+		names = append(names, name)
 	}
 
-	return
+	return adapter.SortLevels(names, func(name string) []string {
+		return adapter.GetDependencies(w.adapters[name])
+	})
 }
 
 // Function receives an adapter interface that can be
@@ -62,3 +166,17 @@ func (w *WorkerAdapters) Get(name string) (adapter.AdapterInterface, error) {
 
 	return nil, fmt.Errorf("adapter %s is not found", name)
 }
+
+// Function checks all adapters implementing HealthCheckInterface
+// and returns a report with the adapter names as check names.
+func (w *WorkerAdapters) HealthCheck(ctx context.Context) *HealthReport {
+	report := NewHealthReport()
+
+	for name, adap := range w.adapters {
+		if checker, ok := adap.(adapter.HealthCheckInterface); ok {
+			report.AddCheck(name, checker.HealthCheck(ctx))
+		}
+	}
+
+	return report
+}
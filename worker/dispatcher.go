@@ -0,0 +1,97 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+// Structure runs message handlers in goroutines. The number of
+// messages processed at once is limited and Submit() blocks while
+// the limit is reached, so consumers stop receiving new messages.
+// Messages with the same ordering key are processed one by one in
+// the order of submission.
+type Dispatcher struct {
+	slots chan struct{}
+
+	mutex sync.Mutex
+	keys  map[string][]func()
+
+	wg sync.WaitGroup
+}
+
+// Function allocates a dispatcher processing the number of messages
+// at once. The concurrency less than 1 means sequential processing.
+func NewDispatcher(concurrency int) *Dispatcher {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return &Dispatcher{slots: make(chan struct{}, concurrency), keys: make(map[string][]func())}
+}
+
+// Function schedules a task. It blocks until the number of pending
+// tasks is below the limit. Tasks with a non-empty key are run
+// after previous tasks with the same key. If the context is
+// cancelled while waiting the task is not scheduled.
+func (d *Dispatcher) Submit(ctx context.Context, key string, task func()) error {
+	select {
+	case d.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	d.wg.Add(1)
+
+	if key == "" {
+		go func() {
+			defer d.release()
+
+			task()
+		}()
+
+		return nil
+	}
+
+	d.mutex.Lock()
+	queue, active := d.keys[key]
+	d.keys[key] = append(queue, task)
+	d.mutex.Unlock()
+
+	if !active {
+		go d.runKey(key)
+	}
+
+	return nil
+}
+
+// Function waits for all scheduled tasks.
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// Function runs tasks of the key until its queue is empty.
+func (d *Dispatcher) runKey(key string) {
+	for {
+		d.mutex.Lock()
+		queue := d.keys[key]
+
+		if len(queue) == 0 {
+			delete(d.keys, key)
+			d.mutex.Unlock()
+
+			return
+		}
+
+		task := queue[0]
+		d.keys[key] = queue[1:]
+		d.mutex.Unlock()
+
+		task()
+		d.release()
+	}
+}
+
+func (d *Dispatcher) release() {
+	<-d.slots
+	d.wg.Done()
+}
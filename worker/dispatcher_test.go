@@ -0,0 +1,166 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestDispatcherKeyOrder(t *testing.T) {
+	d := NewDispatcher(4)
+
+	mutex := sync.Mutex{}
+	order := map[string][]int{}
+	active := map[string]int{}
+	overlapped := atomic.Bool{}
+
+	for idx := 0; idx < 50; idx++ {
+		idx := idx
+		key := []string{"a", "b"}[idx%2]
+
+		err := d.Submit(context.Background(), key, func() {
+			mutex.Lock()
+			active[key]++
+			overlapped.CompareAndSwap(false, active[key] > 1)
+			mutex.Unlock()
+
+			time.Sleep(time.Duration(idx%3) * time.Millisecond)
+
+			mutex.Lock()
+			active[key]--
+			order[key] = append(order[key], idx)
+			mutex.Unlock()
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	d.Wait()
+
+	if overlapped.Load() {
+		t.Error("tasks with the same key run at once")
+	}
+
+	for key, indexes := range order {
+		for idx := 1; idx < len(indexes); idx++ {
+			if indexes[idx] < indexes[idx-1] {
+				t.Fatalf("tasks of the key %s run out of order: %v", key, indexes)
+			}
+		}
+	}
+
+	if len(order["a"]) != 25 || len(order["b"]) != 25 {
+		t.Errorf("not all tasks are run: %v", order)
+	}
+}
+
+func TestDispatcherKeysRunConcurrently(t *testing.T) {
+	d := NewDispatcher(2)
+
+	started := make(chan struct{})
+	done := make(chan struct{})
+
+	// the first task waits for the task of another key
+	d.Submit(context.Background(), "a", func() {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Error("task of another key is not run concurrently")
+		}
+	})
+
+	d.Submit(context.Background(), "b", func() { close(started) })
+
+	go func() {
+		d.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("tasks are not completed")
+	}
+}
+
+func TestDispatcherConcurrencyLimit(t *testing.T) {
+	const limit = 3
+
+	d := NewDispatcher(limit)
+
+	gate := make(chan struct{})
+	active := atomic.Int32{}
+	maximum := atomic.Int32{}
+	submitted := make(chan struct{})
+
+	go func() {
+		defer close(submitted)
+
+		for idx := 0; idx < 10; idx++ {
+			d.Submit(context.Background(), "", func() {
+				current := active.Add(1)
+
+				for {
+					peak := maximum.Load()
+
+					if current <= peak || maximum.CompareAndSwap(peak, current) {
+						break
+					}
+				}
+
+				<-gate
+				active.Add(-1)
+			})
+		}
+	}()
+
+	// Submit() blocks while the limit is reached
+	time.Sleep(50 * time.Millisecond)
+
+	select {
+	case <-submitted:
+		t.Fatal("Submit() does not block at the limit")
+	default:
+	}
+
+	close(gate)
+	<-submitted
+	d.Wait()
+
+	if maximum.Load() != limit {
+		t.Errorf("%d tasks run at once, the limit is %d", maximum.Load(), limit)
+	}
+}
+
+func TestDispatcherSubmitCancel(t *testing.T) {
+	d := NewDispatcher(1)
+
+	gate := make(chan struct{})
+
+	if err := d.Submit(context.Background(), "", func() { <-gate }); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	run := atomic.Bool{}
+
+	err := d.Submit(ctx, "key", func() { run.Store(true) })
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context error, got %v", err)
+	}
+
+	close(gate)
+	d.Wait()
+
+	if run.Load() {
+		t.Error("task is run after the context has been cancelled")
+	}
+}
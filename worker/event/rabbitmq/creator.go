@@ -0,0 +1,39 @@
+package rabbitmq
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/radianteam/framework/adapter/util/config"
+	"github.com/radianteam/framework/worker"
+)
+
+// Function creates the RabbitMQ events worker from a configuration section.
+func RabbitMqEventWorkerCreate(name string, configAdapter *config.ConfigAdapter) (worker.WorkerInterface, error) {
+	if configAdapter == nil {
+		return nil, errors.New("configuration adapter is not provided")
+	}
+
+	workerConfig := &RabbitMqConfig{}
+	err := configAdapter.Unmarshal(workerConfig, false)
+
+	if err != nil {
+		return nil, fmt.Errorf("configuration loading error for the worker with name %s: %v", name, err)
+	}
+
+	return NewRabbitMqEventWorker(name, workerConfig), nil
+}
+
+// Function replaces the broker connection, concurrency, retry and
+// topology settings. The next Run() connects with them, handlers
+// and retry policies set in the code are kept.
+func (w *RabbitMqEventWorker) ApplyConfig(configAdapter *config.ConfigAdapter) error {
+	workerConfig := &RabbitMqConfig{}
+	err := configAdapter.Unmarshal(workerConfig, false)
+
+	if err != nil {
+		return fmt.Errorf("configuration loading error for the worker with name %s: %v", w.GetName(), err)
+	}
+
+	return w.Reconfigure(func() { w.config = workerConfig })
+}
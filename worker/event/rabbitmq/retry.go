@@ -0,0 +1,362 @@
+package rabbitmq
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	rabbitmq_adapter "github.com/radianteam/framework/adapter/event/rabbitmq"
+	"github.com/streadway/amqp"
+)
+
+// Headers of retried and dead-lettered messages.
+const (
+	// number of failed attempts to handle the message
+	HeaderRetryAttempt = "x-retry-attempt"
+
+	// error of the last attempt and the queue where it happened
+	HeaderFailureReason = "x-failure-reason"
+	HeaderFailedQueue   = "x-failed-queue"
+)
+
+// Attempts of a retry policy without MaxAttempts, like the default
+// of the "Retry" configuration.
+const DefaultRetryMaxAttempts = 3
+
+// Policy of messages which cannot be handled. A failed message is
+// published again after the delay, the delay is doubled by every
+// attempt up to the maximum. The message is sent to the dead letter
+// exchange when all attempts have failed.
+type RabbitMqRetryPolicy struct {
+	// attempts to handle a message including the first one
+	MaxAttempts int `json:"MaxAttempts,omitempty" config:"MaxAttempts,default=3,min=1"`
+
+	// zero delay returns a message to the end of the queue at once,
+	// zero maximum delay means no limit
+	Delay    time.Duration `json:"Delay,omitempty" config:"Delay,min=0s"`
+	MaxDelay time.Duration `json:"MaxDelay,omitempty" config:"MaxDelay,min=0s"`
+
+	// the original routing key is used if the key is empty; without
+	// the exchange the dead letter exchange of the queue declared by
+	// the "Topology" is used, otherwise a message is rejected and the
+	// broker moves it to the dead letter exchange of the queue without
+	// the failure reason
+	DeadLetterExchange   string `json:"DeadLetterExchange,omitempty" config:"DeadLetterExchange"`
+	DeadLetterRoutingKey string `json:"DeadLetterRoutingKey,omitempty" config:"DeadLetterRoutingKey"`
+}
+
+// Function returns the delay before the attempt following the
+// failed one.
+func (p *RabbitMqRetryPolicy) delay(attempt int) time.Duration {
+	delay := p.Delay
+
+	for i := 1; i < attempt && delay > 0; i++ {
+		if p.MaxDelay > 0 && delay >= p.MaxDelay {
+			break
+		}
+
+		delay *= 2
+	}
+
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return delay
+}
+
+// Function returns delays of all retries of the policy.
+func (p *RabbitMqRetryPolicy) delays() []time.Duration {
+	delays := []time.Duration{}
+
+	for attempt := 1; attempt < p.MaxAttempts; attempt++ {
+		delays = append(delays, p.delay(attempt))
+	}
+
+	return delays
+}
+
+// Function sets the retry policy of messages with the routing key
+// or the pattern of SetEvent(). It overrides the "Retry" settings
+// of the configuration. A nil policy returns failed messages to the
+// queue at once. Zero MaxAttempts means DefaultRetryMaxAttempts.
+func (w *RabbitMqEventWorker) SetRetryPolicy(queue string, routingKey string, policy *RabbitMqRetryPolicy) {
+	if policy != nil && policy.MaxAttempts < 1 {
+		defaulted := *policy
+		defaulted.MaxAttempts = DefaultRetryMaxAttempts
+		policy = &defaulted
+	}
+
+	if _, ok := w.retryPolicies[queue]; !ok {
+		w.retryPolicies[queue] = make(map[string]*RabbitMqRetryPolicy)
+	}
+
+	w.retryPolicies[queue][routingKey] = policy
+}
+
+// Function returns the retry policy of messages with the routing
+// key or the pattern. The policy of the handler overrides the policy
+// of the queue and the worker.
+func (w *RabbitMqEventWorker) retryPolicy(queue string, routingKey string) *RabbitMqRetryPolicy {
+	if policy, ok := w.retryPolicies[queue][routingKey]; ok {
+		return policy
+	}
+
+	return w.queueRetryPolicy(queue)
+}
+
+// Function returns the retry policy of the queue or the worker.
+func (w *RabbitMqEventWorker) queueRetryPolicy(queue string) *RabbitMqRetryPolicy {
+	if queueConfig, ok := w.config.Queues[queue]; ok && queueConfig.Retry != nil {
+		return queueConfig.Retry
+	}
+
+	return w.config.Retry
+}
+
+// Names of the exchange returning messages to the queue and the
+// exchange with the queue delaying them.
+func requeueExchangeName(queue string) string {
+	return queue + ".requeue"
+}
+
+func retryExchangeName(queue string, delay time.Duration) string {
+	return fmt.Sprintf("%s.retry.%d", queue, delay.Milliseconds())
+}
+
+// Function declares exchanges and queues of delayed retries. A
+// message is published to the fanout exchange of its delay and
+// waits in the queue with the TTL. Then it is dead-lettered to the
+// exchange bound to the consumed queue only, so its routing key is
+// kept and other queues do not receive it again.
+func declareRetryTopology(channel *amqp.Channel, queue string, policies []*RabbitMqRetryPolicy) error {
+	delays := map[time.Duration]bool{}
+
+	for _, policy := range policies {
+		if policy == nil {
+			continue
+		}
+
+		for _, delay := range policy.delays() {
+			delays[delay] = true
+		}
+	}
+
+	if len(delays) == 0 {
+		return nil
+	}
+
+	requeueExchange := requeueExchangeName(queue)
+
+	if err := channel.ExchangeDeclare(requeueExchange, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+		return fmt.Errorf("exchange %s cannot be declared: %w", requeueExchange, err)
+	}
+
+	if err := channel.QueueBind(queue, "", requeueExchange, false, nil); err != nil {
+		return fmt.Errorf("queue %s cannot be bound to %s: %w", queue, requeueExchange, err)
+	}
+
+	for delay := range delays {
+		if delay == 0 {
+			continue
+		}
+
+		name := retryExchangeName(queue, delay)
+
+		if err := channel.ExchangeDeclare(name, amqp.ExchangeFanout, true, false, false, false, nil); err != nil {
+			return fmt.Errorf("exchange %s cannot be declared: %w", name, err)
+		}
+
+		args := amqp.Table{"x-message-ttl": delay.Milliseconds(), "x-dead-letter-exchange": requeueExchange}
+
+		if _, err := channel.QueueDeclare(name, true, false, false, false, args); err != nil {
+			return fmt.Errorf("queue %s cannot be declared: %w", name, err)
+		}
+
+		if err := channel.QueueBind(name, "", name, false, nil); err != nil {
+			return fmt.Errorf("queue %s cannot be bound: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// Structure publishes retried and dead-lettered messages of a queue
+// on a channel of the consumer. The channel is closed with the
+// consumer, so handlers never reconnect the adapter of the worker.
+type retryPublisher struct {
+	mutex    sync.Mutex
+	channel  *amqp.Channel
+	confirms chan amqp.Confirmation
+	sequence uint64
+}
+
+func newRetryPublisher(channel *amqp.Channel) (*retryPublisher, error) {
+	if err := channel.Confirm(false); err != nil {
+		return nil, fmt.Errorf("publishing channel cannot be confirmed: %w", err)
+	}
+
+	confirms := channel.NotifyPublish(make(chan amqp.Confirmation, 1))
+
+	return &retryPublisher{channel: channel, confirms: confirms}, nil
+}
+
+// Function publishes a message and waits for its confirmation. A
+// late confirmation of a timed out message is skipped.
+func (p *retryPublisher) publish(ctx context.Context, exchange string, key string, message amqp.Publishing) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if err := p.channel.Publish(exchange, key, false, false, message); err != nil {
+		return err
+	}
+
+	p.sequence++
+
+	timeout := time.After(rabbitmq_adapter.RabbitMqPublishTimeoutMs * time.Millisecond)
+
+	for {
+		select {
+		case confirmation, ok := <-p.confirms:
+			if !ok {
+				return amqp.ErrClosed
+			}
+
+			if confirmation.DeliveryTag < p.sequence {
+				continue
+			}
+
+			if !confirmation.Ack {
+				return errors.New("publishing is not confirmed")
+			}
+
+			return nil
+		case <-timeout:
+			return errors.New("publishing timeout")
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (p *retryPublisher) close() {
+	if p != nil {
+		p.channel.Close()
+	}
+}
+
+// Function handles a failed message by the policy: it is published
+// to the retry exchange or to the dead letter exchange and then
+// acknowledged. The message is returned to the queue if it cannot be
+// published.
+func (w *RabbitMqEventWorker) fail(queue string, policy *RabbitMqRetryPolicy, publisher *retryPublisher, message *amqp.Delivery, reason error) {
+	if policy == nil || publisher == nil {
+		message.Nack(false, true)
+
+		return
+	}
+
+	attempt := retryAttempt(message.Headers) + 1
+
+	exchange, key := policy.DeadLetterExchange, policy.DeadLetterRoutingKey
+	deadLetter := exchange != ""
+
+	if !deadLetter {
+		exchange, key, deadLetter = w.queueDeadLetter(queue)
+	}
+
+	if attempt < policy.MaxAttempts {
+		delay := policy.delay(attempt)
+		exchange, key = retryExchangeName(queue, delay), message.RoutingKey
+
+		if delay == 0 {
+			exchange = requeueExchangeName(queue)
+		}
+
+		w.Logger.Warnf("Message with delivery tag %d is retried after %s, attempt %d of %d failed", message.DeliveryTag, delay, attempt, policy.MaxAttempts)
+	} else if !deadLetter {
+		// the broker adds the x-death header but the reason is lost
+		w.Logger.Errorf("Message with delivery tag %d is rejected after %d attempts: %v", message.DeliveryTag, attempt, reason)
+		message.Nack(false, false)
+
+		return
+	} else {
+		if key == "" {
+			key = message.RoutingKey
+		}
+
+		w.Logger.Errorf("Message with delivery tag %d is dead-lettered to %s after %d attempts", message.DeliveryTag, exchange, attempt)
+	}
+
+	headers := amqp.Table{}
+
+	for name, value := range message.Headers {
+		headers[name] = value
+	}
+
+	headers[HeaderRetryAttempt] = int32(attempt)
+	headers[HeaderFailureReason] = reason.Error()
+	headers[HeaderFailedQueue] = queue
+
+	err := publisher.publish(w.WorkContext(), exchange, key, amqp.Publishing{
+		Headers:         headers,
+		ContentType:     message.ContentType,
+		ContentEncoding: message.ContentEncoding,
+		DeliveryMode:    message.DeliveryMode,
+		Priority:        message.Priority,
+		CorrelationId:   message.CorrelationId,
+		ReplyTo:         message.ReplyTo,
+		MessageId:       message.MessageId,
+		Timestamp:       message.Timestamp,
+		Type:            message.Type,
+		UserId:          message.UserId,
+		AppId:           message.AppId,
+		Body:            message.Body,
+	})
+
+	if err != nil {
+		w.Logger.Errorf("Message with delivery tag %d cannot be published to %s and is returned to the queue: %v", message.DeliveryTag, exchange, err)
+		message.Nack(false, true)
+
+		return
+	}
+
+	message.Ack(false)
+}
+
+// Function returns the dead letter exchange and routing key of a
+// queue declared by the "Topology" of the worker.
+func (w *RabbitMqEventWorker) queueDeadLetter(queue string) (exchange string, key string, ok bool) {
+	for _, queueConfig := range w.config.Topology.Queues {
+		if queueConfig.Name != queue {
+			continue
+		}
+
+		exchange, ok = queueConfig.Arguments["x-dead-letter-exchange"].(string)
+		key, _ = queueConfig.Arguments["x-dead-letter-routing-key"].(string)
+
+		return
+	}
+
+	return
+}
+
+// Function returns the number of failed attempts from the header.
+func retryAttempt(headers amqp.Table) int {
+	switch value := headers[HeaderRetryAttempt].(type) {
+	case int8:
+		return int(value)
+	case int16:
+		return int(value)
+	case int32:
+		return int(value)
+	case int64:
+		return int(value)
+	case int:
+		return value
+	}
+
+	return 0
+}
@@ -0,0 +1,185 @@
+package rabbitmq
+
+import (
+	"strings"
+
+	"github.com/radianteam/framework/worker"
+	"github.com/streadway/amqp"
+	"golang.org/x/exp/maps"
+)
+
+// Actions with messages which have no handler.
+const (
+	// the message is returned to the queue, another consumer of the
+	// queue can handle it; it is the default
+	UnmatchedRequeue = "requeue"
+
+	// the message is rejected without requeueing, the broker moves
+	// it to the dead letter exchange of the queue if it is declared
+	// and deletes it otherwise
+	UnmatchedReject = "reject"
+
+	// the message is acknowledged and lost
+	UnmatchedDrop = "drop"
+)
+
+type handlerProvider = worker.HandlerProvider[RabbitMqEventHandlerInterface]
+
+// Structure selects handlers of messages of a queue. A routing key
+// is matched with exact keys first and then with patterns in the
+// order of registration. The fallback handler gets other messages.
+type queueRouter struct {
+	exact    map[string]*handlerProvider
+	patterns []routePattern
+	fallback *handlerProvider
+}
+
+type routePattern struct {
+	pattern  string
+	words    []string
+	provider *handlerProvider
+}
+
+func newQueueRouter() *queueRouter {
+	return &queueRouter{exact: make(map[string]*handlerProvider)}
+}
+
+// Function sets the handler of a routing key or a topic pattern. A
+// pattern is a key with "*" or "#" words, e.g. "order.*.created".
+func (r *queueRouter) set(routingKey string, provider *handlerProvider) {
+	words := strings.Split(routingKey, ".")
+
+	if !isRoutePattern(words) {
+		r.exact[routingKey] = provider
+
+		return
+	}
+
+	for idx := range r.patterns {
+		if r.patterns[idx].pattern == routingKey {
+			r.patterns[idx].provider = provider
+
+			return
+		}
+	}
+
+	r.patterns = append(r.patterns, routePattern{pattern: routingKey, words: words, provider: provider})
+}
+
+// Function returns the handler of a routing key and the key or the
+// pattern it has been registered with. Otherwise the fallback
+// handler is returned, it is nil if it is not set.
+func (r *queueRouter) route(routingKey string) (provider *handlerProvider, registration string, fallback bool) {
+	if provider, ok := r.exact[routingKey]; ok {
+		return provider, routingKey, false
+	}
+
+	words := strings.Split(routingKey, ".")
+
+	for _, p := range r.patterns {
+		if matchTopic(p.words, words) {
+			return p.provider, p.pattern, false
+		}
+	}
+
+	return r.fallback, "", true
+}
+
+// Function returns routing keys and patterns of the handlers.
+func (r *queueRouter) keys() []string {
+	keys := maps.Keys(r.exact)
+
+	for _, p := range r.patterns {
+		keys = append(keys, p.pattern)
+	}
+
+	return keys
+}
+
+func isRoutePattern(words []string) bool {
+	for _, word := range words {
+		if word == "*" || word == "#" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Function matches words of a routing key with a topic pattern: "*"
+// matches exactly one word, "#" matches zero or more words.
+func matchTopic(pattern []string, words []string) bool {
+	if len(pattern) == 0 {
+		return len(words) == 0
+	}
+
+	switch pattern[0] {
+	case "#":
+		for idx := 0; idx <= len(words); idx++ {
+			if matchTopic(pattern[1:], words[idx:]) {
+				return true
+			}
+		}
+
+		return false
+	case "*":
+		return len(words) > 0 && matchTopic(pattern[1:], words[1:])
+	}
+
+	return len(words) > 0 && pattern[0] == words[0] && matchTopic(pattern[1:], words[1:])
+}
+
+// Function sets the handler of messages of a queue which do not
+// match any routing key or pattern.
+func (w *RabbitMqEventWorker) SetFallbackEvent(queue string, handler RabbitMqEventHandlerInterface) {
+	w.setFallback(queue, worker.NewSharedHandlerProvider(handler))
+}
+
+// Function sets the factory of fallback handlers of a queue.
+func (w *RabbitMqEventWorker) SetFallbackEventFactory(queue string, factory func() RabbitMqEventHandlerInterface) {
+	w.setFallback(queue, worker.NewHandlerFactoryProvider(factory))
+}
+
+func (w *RabbitMqEventWorker) setFallback(queue string, provider *handlerProvider) {
+	w.router(queue).fallback = provider
+
+	provider.SetLogger(w.Logger.WithField("queue", queue)) // TODO: move to setup
+	provider.SetAdapters(w.Adapters)                       // TODO: move to setup
+}
+
+func (w *RabbitMqEventWorker) router(queue string) *queueRouter {
+	if _, ok := w.handlers[queue]; !ok {
+		w.handlers[queue] = newQueueRouter()
+	}
+
+	return w.handlers[queue]
+}
+
+// Function returns the action with unmatched messages of a queue.
+func (w *RabbitMqEventWorker) unmatchedAction(queue string) string {
+	if queueConfig, ok := w.config.Queues[queue]; ok && queueConfig.Unmatched != "" {
+		return queueConfig.Unmatched
+	}
+
+	if w.config.Unmatched == "" {
+		return UnmatchedRequeue
+	}
+
+	return w.config.Unmatched
+}
+
+// Function handles a message which has no handler.
+func (w *RabbitMqEventWorker) unmatched(queue string, message *amqp.Delivery) {
+	action := w.unmatchedAction(queue)
+
+	w.Logger.Errorf("Queue %s doesn't have a handler for %s routing key, action: %s", queue, message.RoutingKey, action)
+
+	switch action {
+	case UnmatchedDrop:
+		message.Ack(false)
+	case UnmatchedReject:
+		message.Nack(false, false)
+	default:
+		message.Nack(false, true)
+	}
+}
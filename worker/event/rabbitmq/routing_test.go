@@ -0,0 +1,125 @@
+package rabbitmq
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchTopic(t *testing.T) {
+	tests := []struct {
+		pattern    string
+		routingKey string
+		expected   bool
+	}{
+		{"order.created", "order.created", true},
+		{"order.created", "order.updated", false},
+		{"order.*", "order.created", true},
+		{"order.*", "order", false},
+		{"order.*", "order.created.eu", false},
+		{"*.created", "order.created", true},
+		{"order.*.created", "order.eu.created", true},
+		{"order.*.created", "order.created", false},
+		{"#", "order", true},
+		{"#", "order.created.eu", true},
+		{"order.#", "order", true},
+		{"order.#", "order.created", true},
+		{"order.#", "order.created.eu", true},
+		{"order.#", "orders.created", false},
+		{"#.created", "created", true},
+		{"#.created", "order.eu.created", true},
+		{"#.created", "order.created.eu", false},
+		{"order.#.created", "order.created", true},
+		{"order.#.created", "order.eu.west.created", true},
+		{"order.#.created", "order.eu.updated", false},
+		{"#.eu.#", "eu", true},
+		{"#.eu.#", "order.eu.created", true},
+		{"#.eu.#", "order.us.created", false},
+		{"#.#", "order.created", true},
+		{"#.*", "order", true},
+		{"#.*", "", true},
+		{"*.#.*", "order", false},
+		{"*.#.*", "order.created", true},
+		{"*", "", true},
+	}
+
+	for _, test := range tests {
+		actual := matchTopic(strings.Split(test.pattern, "."), strings.Split(test.routingKey, "."))
+
+		if actual != test.expected {
+			t.Errorf("matchTopic(%q, %q) = %v, expected %v", test.pattern, test.routingKey, actual, test.expected)
+		}
+	}
+}
+
+func TestQueueRouterRoute(t *testing.T) {
+	exact := &handlerProvider{}
+	first := &handlerProvider{}
+	second := &handlerProvider{}
+	replaced := &handlerProvider{}
+	fallback := &handlerProvider{}
+
+	router := newQueueRouter()
+	router.set("order.created", exact)
+	router.set("order.*", first)
+	router.set("#.created", second)
+
+	tests := []struct {
+		name         string
+		fallback     *handlerProvider
+		routingKey   string
+		provider     *handlerProvider
+		registration string
+		isFallback   bool
+	}{
+		{"exact key before patterns", nil, "order.created", exact, "order.created", false},
+		{"patterns in order of registration", nil, "order.updated", first, "order.*", false},
+		{"second pattern", nil, "invoice.created", second, "#.created", false},
+		{"no fallback", nil, "invoice.updated", nil, "", true},
+		{"fallback", fallback, "invoice.updated", fallback, "", true},
+		{"fallback is not used for matched keys", fallback, "order.updated", first, "order.*", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			router.fallback = test.fallback
+
+			provider, registration, isFallback := router.route(test.routingKey)
+
+			if provider != test.provider || registration != test.registration || isFallback != test.isFallback {
+				t.Errorf("route(%q) = %p, %q, %v", test.routingKey, provider, registration, isFallback)
+			}
+		})
+	}
+
+	router.fallback = nil
+	router.set("order.*", replaced)
+
+	if provider, _, _ := router.route("order.updated"); provider != replaced {
+		t.Error("pattern is not replaced")
+	}
+
+	if len(router.patterns) != 2 || len(router.keys()) != 3 {
+		t.Errorf("keys %v", router.keys())
+	}
+}
+
+func TestUnmatchedAction(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   RabbitMqConfig
+		expected string
+	}{
+		{"default", RabbitMqConfig{}, UnmatchedRequeue},
+		{"worker", RabbitMqConfig{Unmatched: UnmatchedReject}, UnmatchedReject},
+		{"queue", RabbitMqConfig{Unmatched: UnmatchedReject, Queues: map[string]RabbitMqQueueConfig{"orders": {Unmatched: UnmatchedDrop}}}, UnmatchedDrop},
+		{"other queue", RabbitMqConfig{Queues: map[string]RabbitMqQueueConfig{"invoices": {Unmatched: UnmatchedDrop}}}, UnmatchedRequeue},
+	}
+
+	for _, test := range tests {
+		w := NewRabbitMqEventWorker("test", &test.config)
+
+		if actual := w.unmatchedAction("orders"); actual != test.expected {
+			t.Errorf("%s: action %s, expected %s", test.name, actual, test.expected)
+		}
+	}
+}
@@ -1,23 +1,84 @@
 package rabbitmq
 
-// TODO: refactor to rabbitmq adapter
-
 import (
+	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/prometheus/client_golang/prometheus"
+	rabbitmq_adapter "github.com/radianteam/framework/adapter/event/rabbitmq"
 	"github.com/radianteam/framework/worker"
 	"github.com/sirupsen/logrus"
+	"golang.org/x/exp/slices"
 
 	"github.com/streadway/amqp"
 )
 
+const (
+	DefaultReconnectDelay    = time.Second
+	DefaultMaxReconnectDelay = 30 * time.Second
+
+	// name of the connection check in health reports
+	HealthCheckConnectionName = "rabbitmq"
+)
+
+var errNotConnected = errors.New("consumer is not connected to the broker")
+
+var metricConnected = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "rabbitmq_worker_connected",
+	Help: "Connection state of the rabbitmq worker: 1 is connected and consuming, 0 is reconnecting",
+}, []string{"worker_name"})
+
+var metricReconnects = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "rabbitmq_worker_reconnects_total",
+	Help: "Attempts of the rabbitmq worker to connect again to the broker",
+}, []string{"worker_name"})
+
 type RabbitMqConfig struct {
 	Host          string `json:"Host,omitempty" config:"Host,required"`
-	Port          int16  `json:"Port,omitempty" config:"Port,required"`
+	Port          int16  `json:"Port,omitempty" config:"Port,required,min=1"`
 	Username      string `json:"Username,omitempty" config:"Username,required"`
 	Password      string `json:"Password,omitempty" config:"Password,required"`
-	PrefetchCount int    `json:"PrefetchCount,omitempty" config:"PrefetchCount"`
+	PrefetchCount int    `json:"PrefetchCount,omitempty" config:"PrefetchCount,min=0"`
+
+	// first delay before connecting again, it is doubled by every
+	// failed attempt up to the maximum
+	ReconnectDelay    time.Duration `json:"ReconnectDelay,omitempty" config:"ReconnectDelay,default=1s,min=0s"`
+	MaxReconnectDelay time.Duration `json:"MaxReconnectDelay,omitempty" config:"MaxReconnectDelay,default=30s,min=0s"`
+
+	// zero means connecting until the worker is stopped
+	MaxReconnectAttempts int `json:"MaxReconnectAttempts,omitempty" config:"MaxReconnectAttempts,min=0"`
+
+	// messages of a queue handled at once; messages with the same
+	// routing key are handled in order if Ordered is set
+	Concurrency int  `json:"Concurrency,omitempty" config:"Concurrency,default=1,min=1"`
+	Ordered     bool `json:"Ordered,omitempty" config:"Ordered"`
+
+	// exchanges, queues and bindings declared before consuming
+	Topology rabbitmq_adapter.RabbitMqTopology `json:"Topology,omitempty" config:"Topology"`
+
+	// policy of failed messages, they are returned to the queue at
+	// once without it
+	Retry *RabbitMqRetryPolicy `json:"Retry,omitempty" config:"Retry"`
+
+	// action with messages without a handler: requeue, reject or drop
+	Unmatched string `json:"Unmatched,omitempty" config:"Unmatched,default=requeue,oneof=reject|drop|requeue"`
+
+	// settings of particular queues by names
+	Queues map[string]RabbitMqQueueConfig `json:"Queues,omitempty" config:"Queues"`
+}
+
+// Settings of a queue. Zero values mean the settings of the worker.
+type RabbitMqQueueConfig struct {
+	Concurrency   int  `json:"Concurrency,omitempty" config:"Concurrency,min=0"`
+	PrefetchCount int  `json:"PrefetchCount,omitempty" config:"PrefetchCount,min=0"`
+	Ordered       bool `json:"Ordered,omitempty" config:"Ordered"`
+
+	Retry     *RabbitMqRetryPolicy `json:"Retry,omitempty" config:"Retry"`
+	Unmatched string               `json:"Unmatched,omitempty" config:"Unmatched,oneof=reject|drop|requeue"`
 }
 
 type RabbitMqEventWorker struct {
@@ -25,122 +86,431 @@ type RabbitMqEventWorker struct {
 
 	config *RabbitMqConfig
 
-	connection *amqp.Connection
-
-	mutex    sync.Mutex
-	waitChan chan bool
+	adapter   *rabbitmq_adapter.RabbitMqAdapter
+	connected atomic.Bool
 
-	handlers map[string]map[string]RabbitMqEventHandlerInterface
+	handlers      map[string]*queueRouter
+	retryPolicies map[string]map[string]*RabbitMqRetryPolicy
 }
 
 func NewRabbitMqEventWorker(name string, config *RabbitMqConfig) *RabbitMqEventWorker {
-	handlers := make(map[string]map[string]RabbitMqEventHandlerInterface)
-	return &RabbitMqEventWorker{BaseWorker: worker.NewBaseWorker(name), config: config, handlers: handlers}
+	handlers := make(map[string]*queueRouter)
+	retryPolicies := make(map[string]map[string]*RabbitMqRetryPolicy)
+
+	return &RabbitMqEventWorker{BaseWorker: worker.NewBaseWorker(name), config: config, handlers: handlers, retryPolicies: retryPolicies}
 }
 
+// Function sets the handler of messages with the routing key or the
+// topic pattern, e.g. "order.*" or "audit.#". The handler instance
+// is shared, so messages of the key are handled
+// one by one. Use SetEventFactory() to handle them concurrently.
 func (w *RabbitMqEventWorker) SetEvent(queue string, routingKey string, handler RabbitMqEventHandlerInterface) {
-	if _, ok := w.handlers[queue]; !ok {
-		w.handlers[queue] = make(map[string]RabbitMqEventHandlerInterface)
+	w.setProvider(queue, routingKey, worker.NewSharedHandlerProvider(handler))
+}
+
+// Function sets the factory creating a handler for every message
+// with the routing key.
+func (w *RabbitMqEventWorker) SetEventFactory(queue string, routingKey string, factory func() RabbitMqEventHandlerInterface) {
+	w.setProvider(queue, routingKey, worker.NewHandlerFactoryProvider(factory))
+}
+
+func (w *RabbitMqEventWorker) setProvider(queue string, routingKey string, provider *handlerProvider) {
+	w.router(queue).set(routingKey, provider)
+
+	provider.SetLogger(w.Logger.WithFields(logrus.Fields{"queue": queue, "routing_key": routingKey})) // TODO: move to setup
+	provider.SetAdapters(w.Adapters)                                                                  // TODO: move to setup
+}
+
+// Function returns the concurrency, the prefetch count and the
+// ordering of a queue. Settings of the queue override the settings
+// of the worker. The prefetch count is the concurrency by default.
+func (w *RabbitMqEventWorker) queueSettings(name string) (concurrency int, prefetch int, ordered bool) {
+	concurrency, prefetch, ordered = w.config.Concurrency, w.config.PrefetchCount, w.config.Ordered
+
+	if queue, ok := w.config.Queues[name]; ok {
+		if queue.Concurrency > 0 {
+			concurrency = queue.Concurrency
+		}
+
+		if queue.PrefetchCount > 0 {
+			prefetch = queue.PrefetchCount
+		}
+
+		ordered = ordered || queue.Ordered
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
 	}
 
-	w.handlers[queue][routingKey] = handler
+	if prefetch == 0 {
+		prefetch = concurrency
+	}
 
-	w.handlers[queue][routingKey].SetLogger(w.Logger.WithFields(logrus.Fields{"queue": queue, "routing_key": routingKey})) // TODO: move to setup
-	w.handlers[queue][routingKey].SetAdapters(w.Adapters)                                                                  // TODO: move to setup
+	return
 }
 
-func (w *RabbitMqEventWorker) Setup() {
+func (w *RabbitMqEventWorker) Setup() error {
 	w.Logger.Info("Setting up RabbitMq Events")
+
+	for _, c := range []prometheus.Collector{metricConnected, metricReconnects} {
+		if err := prometheus.Register(c); err != nil {
+			if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+				return err
+			}
+		}
+	}
+
+	for queue := range w.handlers {
+		if w.unmatchedAction(queue) != UnmatchedReject {
+			continue
+		}
+
+		if _, _, ok := w.queueDeadLetter(queue); !ok {
+			w.Logger.Warnf("Unmatched messages of the queue %s are rejected, they are lost unless the queue has a dead letter exchange", queue)
+		}
+	}
+
+	return nil
 }
 
-func (w *RabbitMqEventWorker) Run() {
+// Function consumes all queues until the context is cancelled.
+// When the connection or a delivery channel is lost the worker
+// connects again with an exponential backoff and resumes consuming.
+// After the context is cancelled it stops consuming and waits for
+// in-flight messages until ForceStop() is called. Unacknowledged
+// messages are requeued by the broker.
+func (w *RabbitMqEventWorker) Run(ctx context.Context) (err error) {
 	w.Logger.Info("Running RabbitMq Events")
 
-	var err error
-	w.connection, err = amqp.Dial(fmt.Sprintf("amqp://%s:%s@%s:%d/", w.config.Username, w.config.Password, w.config.Host, w.config.Port))
+	ctx, cancel := w.RunContext(ctx)
+	defer cancel()
 
-	if err != nil {
-		w.Logger.Fatalf("dial %s\n", err)
+	w.adapter = rabbitmq_adapter.NewRabbitMqAdapter(w.GetName()+"-consumer", &rabbitmq_adapter.RabbitMqConfig{
+		Host:     w.config.Host,
+		Port:     uint16(w.config.Port),
+		Username: w.config.Username,
+		Password: w.config.Password,
+		Topology: w.config.Topology,
+	})
+	w.adapter.SetLogger(w.Logger.WithField("adapter", w.adapter.GetName()))
+
+	for attempt := 0; ctx.Err() == nil; {
+		if attempt > 0 {
+			delay := w.reconnectDelay(attempt)
+
+			w.Logger.Warnf("Reconnecting to RabbitMq in %s, attempt %d", delay, attempt)
+
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(delay):
+			}
+
+			if w.IsMonitoringEnable() {
+				metricReconnects.With(prometheus.Labels{"worker_name": w.GetName()}).Inc()
+			}
+		}
+
+		started, err := w.session(ctx)
+
+		if ctx.Err() != nil {
+			break
+		}
+
+		if started {
+			attempt = 0
+		}
+
+		attempt++
+
+		w.Logger.Errorf("RabbitMq consuming is interrupted: %v", err)
+
+		if w.config.MaxReconnectAttempts > 0 && attempt > w.config.MaxReconnectAttempts {
+			return fmt.Errorf("connection is not restored after %d attempts: %w", w.config.MaxReconnectAttempts, err)
+		}
 	}
 
+	return nil
+}
+
+// Function connects to the broker and consumes all queues until the
+// context is cancelled or the connection is lost. It reports whether
+// all queues have been consumed before the session has ended.
+func (w *RabbitMqEventWorker) session(ctx context.Context) (started bool, err error) {
+	if err = w.adapter.Setup(); err != nil {
+		w.adapter.Close()
+
+		return false, fmt.Errorf("connection failed: %w", err)
+	}
+
+	closed := w.adapter.NotifyConnectionClose()
+
+	ctx, stopConsuming := context.WithCancel(ctx)
+	defer stopConsuming()
+
 	wg := sync.WaitGroup{}
+	failures := make(chan error, len(w.handlers))
+	consuming := atomic.Int32{}
+
+	onConsuming := func() {
+		if int(consuming.Add(1)) == len(w.handlers) {
+			w.setConnected(true)
+			w.SetReady(true)
+		}
+	}
+
+	if len(w.handlers) == 0 {
+		w.setConnected(true)
+		w.SetReady(true)
+	}
 
-	for queueName, routingKeys := range w.handlers {
+	for queueName, router := range w.handlers {
 		wg.Add(1)
 
-		go func(name string, handlers map[string]RabbitMqEventHandlerInterface) {
+		go func(name string, router *queueRouter) {
 			defer wg.Done()
 
-			w.Logger.Infof("Consuming queue %s", name)
+			if err := w.consume(ctx, name, router, onConsuming); err != nil {
+				failures <- fmt.Errorf("queue %s: %w", name, err)
+			}
+		}(queueName, router)
+	}
 
-			channel, err := w.connection.Channel()
+	select {
+	case <-ctx.Done():
+	case err = <-failures:
+	case amqpErr, ok := <-closed:
+		err = errors.New("connection has been closed")
 
-			if err != nil {
-				w.Logger.Fatalf("channel create %s\n", err)
-			}
+		if ok && amqpErr != nil {
+			err = fmt.Errorf("connection has been closed: %w", amqpErr)
+		}
+	}
 
-			err = channel.Qos(int(w.config.PrefetchCount), 0, false) // TODO: hardcode
-			if err != nil {
-				w.Logger.Fatalf("Cannot prepare qos - %s\n", err)
-			}
+	started = int(consuming.Load()) == len(w.handlers)
 
-			msgs, err := channel.Consume(name, w.GetName(), false, false, false, false, nil) // TODO: hardcoded values
+	stopConsuming()
 
-			if err != nil {
-				w.Logger.Fatalf("Consuming queue %s started with error %v", name, err)
-			}
+	w.SetReady(false)
+	w.setConnected(false)
 
-			for message := range msgs {
-				w.Logger.Infof("Received a message from %s with key %s", name, message.RoutingKey)
-				w.Logger.Debugf("Received message body: %s", message.Body)
+	w.Logger.Info("Stopping RabbitMq Events")
 
-				handler, ok := handlers[message.RoutingKey]
+	done := make(chan struct{})
 
-				if !ok {
-					w.Logger.Errorf("Queue %s doesn't have a handler for %s routing key", name, message.RoutingKey)
-					message.Acknowledger.Nack(message.DeliveryTag, false, true) // TODO: remove hardcode
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
 
-					continue
-				}
+	select {
+	case <-done:
+	case <-w.WorkContext().Done():
+		w.Logger.Error("RabbitMq Events forced to shutdown")
+	}
 
-				var err error
+	w.adapter.Close()
 
-				//single thread processing. contexts can be none thread safe!
-				w.mutex.Lock()
-				handler.SetMqMessage(&message)
-				err = handler.Handle()
-				w.mutex.Unlock()
+	<-done
 
-				if err != nil {
-					w.Logger.Errorf("Queue %s routing key %s failed to proceed the message with delivery tag %d ", name, message.RoutingKey, message.DeliveryTag)
-					message.Nack(true, true) // TODO: remove hardcode
+	return started, err
+}
+
+// Function returns the delay before the attempt to connect. The
+// delay is doubled by every failed attempt up to the maximum.
+func (w *RabbitMqEventWorker) reconnectDelay(attempt int) time.Duration {
+	delay, maxDelay := w.config.ReconnectDelay, w.config.MaxReconnectDelay
+
+	if delay <= 0 {
+		delay = DefaultReconnectDelay
+	}
+
+	if maxDelay <= 0 {
+		maxDelay = DefaultMaxReconnectDelay
+	}
+
+	for i := 1; i < attempt && delay < maxDelay; i++ {
+		delay *= 2
+	}
+
+	if delay > maxDelay {
+		delay = maxDelay
+	}
+
+	return delay
+}
 
-					continue
-				}
+func (w *RabbitMqEventWorker) setConnected(connected bool) {
+	w.connected.Store(connected)
+
+	if !w.IsMonitoringEnable() {
+		return
+	}
 
-				message.Ack(true)
+	value := 0.0
+
+	if connected {
+		value = 1
+	}
+
+	metricConnected.With(prometheus.Labels{"worker_name": w.GetName()}).Set(value)
+}
+
+// Function checks adapters of the worker and the connection of the
+// consumer to the broker.
+func (w *RabbitMqEventWorker) CheckHealth(ctx context.Context) *worker.HealthReport {
+	report := w.BaseWorker.CheckHealth(ctx)
+
+	if w.connected.Load() {
+		report.AddCheck(HealthCheckConnectionName, nil)
+	} else {
+		report.AddCheck(HealthCheckConnectionName, errNotConnected)
+	}
+
+	return report
+}
+
+// Function consumes a queue until the connection is closed. It
+// returns an error if the delivery channel has been closed by
+// the broker and not by the context cancellation. Messages are
+// handled concurrently; the broker does not deliver more messages
+// than the prefetch count until they are acknowledged.
+func (w *RabbitMqEventWorker) consume(ctx context.Context, name string, router *queueRouter, onConsuming func()) error {
+	w.Logger.Infof("Consuming queue %s", name)
+
+	concurrency, prefetch, ordered := w.queueSettings(name)
+
+	if prefetch < concurrency {
+		w.Logger.Warnf("Prefetch count %d of the queue %s limits the concurrency %d", prefetch, name, concurrency)
+	}
+
+	channel, err := w.adapter.OpenChannel()
+
+	if err != nil {
+		return fmt.Errorf("channel create %w", err)
+	}
+
+	defer channel.Close()
+
+	err = channel.Qos(prefetch, 0, false)
+	if err != nil {
+		return fmt.Errorf("cannot prepare qos - %w", err)
+	}
+
+	policies := []*RabbitMqRetryPolicy{w.queueRetryPolicy(name)}
+
+	for _, routingKey := range router.keys() {
+		policies = append(policies, w.retryPolicy(name, routingKey))
+	}
+
+	if err = declareRetryTopology(channel, name, policies); err != nil {
+		return err
+	}
+
+	var publisher *retryPublisher
+
+	if slices.IndexFunc(policies, func(p *RabbitMqRetryPolicy) bool { return p != nil }) >= 0 {
+		publishing, err := w.adapter.OpenChannel()
+
+		if err != nil {
+			return fmt.Errorf("publishing channel create %w", err)
+		}
+
+		if publisher, err = newRetryPublisher(publishing); err != nil {
+			publishing.Close()
+
+			return err
+		}
+
+		defer publisher.close()
+	}
+
+	msgs, err := channel.Consume(name, w.GetName(), false, false, false, false, nil) // TODO: hardcoded values
+
+	if err != nil {
+		return fmt.Errorf("consuming started with error %w", err)
+	}
+
+	onConsuming()
+
+	dispatcher := worker.NewDispatcher(concurrency)
+	ok := true
+
+consuming:
+	for {
+		var message amqp.Delivery
+
+		select {
+		case <-ctx.Done():
+			break consuming
+		case message, ok = <-msgs:
+			if !ok {
+				break consuming
 			}
+		}
+
+		w.Logger.Infof("Received a message from %s with key %s", name, message.RoutingKey)
+		w.Logger.Debugf("Received message body: %s", message.Body)
+
+		delivery := message
+		provider, registration, fallback := router.route(message.RoutingKey)
+
+		if provider == nil {
+			w.unmatched(name, &delivery)
+
+			continue
+		}
+
+		policy := w.queueRetryPolicy(name)
+
+		if !fallback {
+			policy = w.retryPolicy(name, registration)
+		}
 
-			channel.Cancel(name, false)
-			channel.Close()
+		key := ""
 
-			w.Logger.Infof("Consuming queue %s stopped", name)
-		}(queueName, routingKeys)
+		if ordered {
+			key = message.RoutingKey
+		}
+
+		// unacknowledged messages are delivered again after reconnecting
+		if err := dispatcher.Submit(ctx, key, func() { w.handle(name, provider, policy, publisher, &delivery) }); err != nil {
+			break consuming
+		}
 	}
 
-	w.waitChan = make(chan bool)
+	dispatcher.Wait()
 
-	<-w.waitChan
+	channel.Cancel(w.GetName(), false)
 
-	w.Logger.Info("Stopping RabbitMq Events")
+	w.Logger.Infof("Consuming queue %s stopped", name)
 
-	w.connection.Close()
+	if ctx.Err() != nil {
+		return nil
+	}
 
-	wg.Wait()
+	return errors.New("delivery channel has been closed")
 }
 
-func (w *RabbitMqEventWorker) Stop() {
-	w.Logger.Info("stop signal received! Graceful shutting down")
+// Function handles a message and acknowledges it. A failed message
+// is handled by the retry policy.
+func (w *RabbitMqEventWorker) handle(name string, provider *handlerProvider, policy *RabbitMqRetryPolicy, publisher *retryPublisher, message *amqp.Delivery) {
+	handler, release := provider.Acquire()
+	defer release()
+
+	handlerCtx, cancelHandler := w.HandlerContext(w.WorkContext())
+	defer cancelHandler()
+
+	handler.SetContext(handlerCtx)
+	handler.SetMqMessage(message)
+
+	if err := handler.Handle(); err != nil {
+		w.Logger.Errorf("Queue %s routing key %s failed to proceed the message with delivery tag %d: %v", name, message.RoutingKey, message.DeliveryTag, err)
+		w.fail(name, policy, publisher, message, err)
+
+		return
+	}
 
-	close(w.waitChan)
+	message.Ack(false)
 }
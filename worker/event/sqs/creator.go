@@ -0,0 +1,38 @@
+package sqs
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/radianteam/framework/adapter/util/config"
+	"github.com/radianteam/framework/worker"
+)
+
+// Function creates the AWS SQS events worker from a configuration section.
+func AwsSqsEventsWorkerCreate(name string, configAdapter *config.ConfigAdapter) (worker.WorkerInterface, error) {
+	if configAdapter == nil {
+		return nil, errors.New("configuration adapter is not provided")
+	}
+
+	workerConfig := &AwsSqsWorkerConfig{}
+	err := configAdapter.Unmarshal(workerConfig, false)
+
+	if err != nil {
+		return nil, fmt.Errorf("configuration loading error for the worker with name %s: %v", name, err)
+	}
+
+	return NewAwsSqsEventsWorkerWithConfig(name, workerConfig), nil
+}
+
+// Function replaces the AWS connection, polling and concurrency
+// settings. The next Run() polls with them, handlers are kept.
+func (w *AwsSqsEventsWorker) ApplyConfig(configAdapter *config.ConfigAdapter) error {
+	workerConfig := &AwsSqsWorkerConfig{}
+	err := configAdapter.Unmarshal(workerConfig, false)
+
+	if err != nil {
+		return fmt.Errorf("configuration loading error for the worker with name %s: %v", w.GetName(), err)
+	}
+
+	return w.Reconfigure(func() { w.config = workerConfig })
+}
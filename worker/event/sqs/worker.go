@@ -1,115 +1,237 @@
 package sqs
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
 	sqs_adapter "github.com/radianteam/framework/adapter/event/sqs"
 	"github.com/radianteam/framework/worker"
 )
 
 const RetryConsumeTimeoutMs = 10000
 
+type AwsSqsWorkerConfig struct {
+	sqs_adapter.AwsSqsConfig
+
+	// messages of a queue handled at once; messages with the same
+	// group id of FIFO queues are handled in order if Ordered is set
+	Concurrency int  `json:"Concurrency,omitempty" config:"Concurrency,default=1,min=1"`
+	Ordered     bool `json:"Ordered,omitempty" config:"Ordered"`
+
+	// settings of particular queues by names
+	Queues map[string]AwsSqsQueueConfig `json:"Queues,omitempty" config:"Queues"`
+}
+
+// Settings of a queue. Zero values mean the settings of the worker.
+type AwsSqsQueueConfig struct {
+	Concurrency int  `json:"Concurrency,omitempty" config:"Concurrency,min=0"`
+	Ordered     bool `json:"Ordered,omitempty" config:"Ordered"`
+}
+
 type AwsSqsEventsWorker struct {
 	*worker.BaseWorker
 
-	config *sqs_adapter.AwsSqsConfig
-
-	mutex       sync.Mutex
-	waitChan    chan struct{}
-	stopPolling bool
+	config *AwsSqsWorkerConfig
 
-	handlers map[string]AwsSqsEventHandlerInterface
+	handlers map[string]*worker.HandlerProvider[AwsSqsEventHandlerInterface]
 }
 
+// Function allocates a worker handling messages one by one.
 func NewAwsSqsEventsWorker(name string, config *sqs_adapter.AwsSqsConfig) *AwsSqsEventsWorker {
-	handlers := make(map[string]AwsSqsEventHandlerInterface)
+	return NewAwsSqsEventsWorkerWithConfig(name, &AwsSqsWorkerConfig{AwsSqsConfig: *config, Concurrency: 1})
+}
+
+func NewAwsSqsEventsWorkerWithConfig(name string, config *AwsSqsWorkerConfig) *AwsSqsEventsWorker {
+	handlers := make(map[string]*worker.HandlerProvider[AwsSqsEventHandlerInterface])
 
 	return &AwsSqsEventsWorker{BaseWorker: worker.NewBaseWorker(name), config: config, handlers: handlers}
 }
 
+// Function sets the handler of a queue. The handler instance is
+// shared, so messages of the queue are handled one by one. Use
+// SetEventFactory() to handle them concurrently.
 func (w *AwsSqsEventsWorker) SetEvent(queue string, handler AwsSqsEventHandlerInterface) {
-	w.handlers[queue] = handler
+	w.handlers[queue] = worker.NewSharedHandlerProvider(handler)
+}
+
+// Function sets the factory creating a handler for every message
+// of a queue.
+func (w *AwsSqsEventsWorker) SetEventFactory(queue string, factory func() AwsSqsEventHandlerInterface) {
+	w.handlers[queue] = worker.NewHandlerFactoryProvider(factory)
+}
+
+// Function returns the concurrency and the ordering of a queue.
+// Settings of the queue override the settings of the worker.
+func (w *AwsSqsEventsWorker) queueSettings(name string) (concurrency int, ordered bool) {
+	concurrency, ordered = w.config.Concurrency, w.config.Ordered
+
+	if queue, ok := w.config.Queues[name]; ok {
+		if queue.Concurrency > 0 {
+			concurrency = queue.Concurrency
+		}
+
+		ordered = ordered || queue.Ordered
+	}
+
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	return
 }
 
-func (w *AwsSqsEventsWorker) Setup() {
+func (w *AwsSqsEventsWorker) Setup() error {
 	w.Logger.Info("Setting up Sqs Events")
+
+	return nil
 }
 
-func (w *AwsSqsEventsWorker) Run() {
+// Function polls all queues until the context is cancelled or
+// a handled message cannot be deleted. Then it waits for in-flight
+// messages until ForceStop() is called.
+func (w *AwsSqsEventsWorker) Run(ctx context.Context) error {
 	w.Logger.Info("Running Sqs Events Worker")
 
+	ctx, cancel := w.RunContext(ctx)
+	defer cancel()
+
+	ctx, stopConsuming := context.WithCancel(ctx)
+	defer stopConsuming()
+
 	wg := sync.WaitGroup{}
 
-	adapter := sqs_adapter.NewAwsSqsAdapter("sqs-consumer", w.config)
+	adapter := sqs_adapter.NewAwsSqsAdapter("sqs-consumer", &w.config.AwsSqsConfig)
 	err := adapter.Setup()
 	if err != nil {
-		w.Logger.Errorf("Failed to run '%s' worker during adapter configuration: %s", w.GetName(), err)
+		return fmt.Errorf("failed to run '%s' worker during adapter configuration: %w", w.GetName(), err)
+	}
 
-		return
+	failures := make(chan error, 1)
+
+	fail := func(err error) {
+		select {
+		case failures <- err:
+		default:
+		}
 	}
 
-	for queueName, handler := range w.handlers {
+	for queueName, provider := range w.handlers {
 		wg.Add(1)
 
-		go func(qName string, handler AwsSqsEventHandlerInterface) {
+		go func(qName string, provider *worker.HandlerProvider[AwsSqsEventHandlerInterface]) {
 			defer wg.Done()
 
-			handler.SetLogger(w.Logger.WithField("queue", qName))
-			handler.SetAdapters(w.Adapters)
+			provider.SetLogger(w.Logger.WithField("queue", qName))
+			provider.SetAdapters(w.Adapters)
 
-			w.Logger.Infof("Consuming queue '%s'", qName)
-			for {
-				if w.stopPolling {
-					break
-				}
+			concurrency, ordered := w.queueSettings(qName)
+			dispatcher := worker.NewDispatcher(concurrency)
+			defer dispatcher.Wait()
 
-				msgs, err := adapter.Consume(qName)
+			w.Logger.Infof("Consuming queue '%s'", qName)
+			for ctx.Err() == nil {
+				msgs, err := adapter.ConsumeContext(ctx, qName)
 				if err != nil {
+					if ctx.Err() != nil {
+						break
+					}
+
 					w.Logger.Errorf("Consuming queue '%s' started with error: %v", qName, err)
 
-					time.Sleep(RetryConsumeTimeoutMs * time.Millisecond)
+					select {
+					case <-time.After(RetryConsumeTimeoutMs * time.Millisecond):
+					case <-ctx.Done():
+					}
+
 					continue
 				}
 
 				for _, message := range msgs {
+					if ctx.Err() != nil {
+						break
+					}
+
 					w.Logger.Infof("Received a message from '%s'", qName)
 					w.Logger.Debugf("Received message body: '%s'", aws.StringValue(message.Body))
 
-					// Single thread processing. Adapters can be none thread safe!
-					w.mutex.Lock()
-					handler.SetSqsMessage(message)
-					err = handler.Handle()
-					w.mutex.Unlock()
+					key := ""
 
-					if err != nil {
-						w.Logger.Errorf("Queue '%s' failed to proceed the message '%s' with error '%v'", qName, message, err)
-
-						return
+					if ordered {
+						key = aws.StringValue(message.Attributes[sqs.MessageSystemAttributeNameMessageGroupId])
 					}
 
-					err = adapter.DeleteMessage(qName, *message.ReceiptHandle)
-					if err != nil {
-						w.Logger.Errorf("Failed to delete the message '%s' from queue '%s' with error '%v'", message, qName, err)
+					message := message
 
-						return
+					// not processed messages become visible again after the visibility timeout
+					if err := dispatcher.Submit(ctx, key, func() {
+						if err := w.handle(adapter, qName, provider, message); err != nil {
+							fail(err)
+						}
+					}); err != nil {
+						break
 					}
 				}
 
 				w.Logger.Debugf("Consuming queue '%s' stopped", qName)
 			}
-		}(queueName, handler)
+		}(queueName, provider)
+	}
+
+	w.SetReady(true)
+
+	select {
+	case <-ctx.Done():
+	case err = <-failures:
 	}
 
-	w.waitChan = make(chan struct{})
-	<-w.waitChan
-	wg.Wait()
+	stopConsuming()
+
+	w.SetReady(false)
+
+	done := make(chan struct{})
+
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-w.WorkContext().Done():
+		w.Logger.Error("Sqs Events Worker forced to shutdown")
+
+		<-done
+	}
+
+	return err
 }
 
-func (w *AwsSqsEventsWorker) Stop() {
-	w.Logger.Info("stop signal received! Graceful shutting down")
+// Function handles a message and deletes it from the queue. A
+// failed message is logged and kept, it becomes visible again after
+// the visibility timeout. Only errors of the service are returned.
+func (w *AwsSqsEventsWorker) handle(adapter *sqs_adapter.AwsSqsAdapter, qName string, provider *worker.HandlerProvider[AwsSqsEventHandlerInterface], message *sqs.Message) error {
+	handler, release := provider.Acquire()
+	defer release()
+
+	handlerCtx, cancelHandler := w.HandlerContext(w.WorkContext())
+	defer cancelHandler()
+
+	handler.SetContext(handlerCtx)
+	handler.SetSqsMessage(message)
+
+	if err := handler.Handle(); err != nil {
+		w.Logger.Errorf("Queue '%s' failed to proceed the message '%s' with error: %v", qName, aws.StringValue(message.MessageId), err)
+
+		return nil
+	}
+
+	if err := adapter.DeleteMessageContext(context.Background(), qName, *message.ReceiptHandle); err != nil {
+		return fmt.Errorf("failed to delete the message '%s' from queue '%s' with error '%w'", aws.StringValue(message.MessageId), qName, err)
+	}
 
-	w.stopPolling = true
-	close(w.waitChan)
+	return nil
 }
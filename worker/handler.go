@@ -1,7 +1,9 @@
 package worker
 
 import (
+	"context"
 	"errors"
+	"sync"
 
 	"github.com/sirupsen/logrus"
 )
@@ -10,11 +12,14 @@ type BaseHandlerInterface interface {
 	Handle() error
 	SetLogger(l *logrus.Entry)
 	SetAdapters(a *WorkerAdapters)
+	SetContext(ctx context.Context)
 }
 
 type BaseHandler struct {
 	Logger   *logrus.Entry
 	Adapters *WorkerAdapters
+
+	ctx context.Context
 }
 
 func (h *BaseHandler) Handle() error {
@@ -28,3 +33,79 @@ func (h *BaseHandler) SetLogger(l *logrus.Entry) {
 func (h *BaseHandler) SetAdapters(a *WorkerAdapters) {
 	h.Adapters = a
 }
+
+// Function sets a request or message scoped context. It is
+// set by a worker before every Handle() call.
+func (h *BaseHandler) SetContext(ctx context.Context) {
+	h.ctx = ctx
+}
+
+// Function returns the context of the current request or
+// message. The context is cancelled when the worker is stopping
+// or the handler deadline is exceeded. Pass it to adapters.
+func (h *BaseHandler) Context() context.Context {
+	if h.ctx == nil {
+		return context.Background()
+	}
+
+	return h.ctx
+}
+
+// Structure provides handlers for messages of event workers. A
+// shared instance is used by one message at a time, a factory
+// creates an instance for every message so messages are handled
+// concurrently.
+type HandlerProvider[T BaseHandlerInterface] struct {
+	mutex   sync.Mutex
+	shared  T
+	factory func() T
+
+	logger   *logrus.Entry
+	adapters *WorkerAdapters
+}
+
+// Function allocates a provider of the shared handler instance.
+func NewSharedHandlerProvider[T BaseHandlerInterface](handler T) *HandlerProvider[T] {
+	return &HandlerProvider[T]{shared: handler}
+}
+
+// Function allocates a provider creating a handler instance for
+// every message.
+func NewHandlerFactoryProvider[T BaseHandlerInterface](factory func() T) *HandlerProvider[T] {
+	return &HandlerProvider[T]{factory: factory}
+}
+
+// Function sets the logger of handlers.
+func (p *HandlerProvider[T]) SetLogger(l *logrus.Entry) {
+	p.logger = l
+
+	if p.factory == nil {
+		p.shared.SetLogger(l)
+	}
+}
+
+// Function sets adapters of handlers.
+func (p *HandlerProvider[T]) SetAdapters(a *WorkerAdapters) {
+	p.adapters = a
+
+	if p.factory == nil {
+		p.shared.SetAdapters(a)
+	}
+}
+
+// Function returns a handler for a message and the function which
+// must be called after the message has been handled. The shared
+// instance is locked until it is released.
+func (p *HandlerProvider[T]) Acquire() (T, func()) {
+	if p.factory != nil {
+		handler := p.factory()
+		handler.SetLogger(p.logger)
+		handler.SetAdapters(p.adapters)
+
+		return handler, func() {}
+	}
+
+	p.mutex.Lock()
+
+	return p.shared, p.mutex.Unlock
+}
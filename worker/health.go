@@ -0,0 +1,77 @@
+package worker
+
+import (
+	"context"
+	"sync"
+)
+
+type HealthStatus string
+
+const (
+	HealthStatusUp   HealthStatus = "up"
+	HealthStatusDown HealthStatus = "down"
+)
+
+// Structure contains an aggregated status and results of
+// particular checks. Failed checks contain an error text.
+type HealthReport struct {
+	mutex sync.Mutex
+
+	Status HealthStatus      `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Interface is implemented by microservices and the manager to
+// provide health and readiness reports to workers.
+type HealthProviderInterface interface {
+	CheckHealth(ctx context.Context) *HealthReport
+	CheckReadiness(ctx context.Context) *HealthReport
+}
+
+// Optional interface for workers which expose health reports
+// (e.g. the monitoring worker). The provider is set by the
+// microservice the worker is added to.
+type HealthConsumerInterface interface {
+	SetHealthProvider(provider HealthProviderInterface)
+}
+
+// Function allocates a report with the "up" status and an
+// empty (but not nil!) check list.
+func NewHealthReport() *HealthReport {
+	return &HealthReport{Status: HealthStatusUp, Checks: make(map[string]string)}
+}
+
+// Function appends a check result. Any error switches the
+// report status to "down".
+func (r *HealthReport) AddCheck(name string, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if err != nil {
+		r.Status = HealthStatusDown
+		r.Checks[name] = err.Error()
+
+		return
+	}
+
+	r.Checks[name] = string(HealthStatusUp)
+}
+
+// Function appends all checks of another report with a prefix.
+func (r *HealthReport) Merge(prefix string, other *HealthReport) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if other.Status != HealthStatusUp {
+		r.Status = HealthStatusDown
+	}
+
+	for name, result := range other.Checks {
+		r.Checks[prefix+name] = result
+	}
+}
+
+// Function returns true if all checks are passed.
+func (r *HealthReport) IsUp() bool {
+	return r.Status == HealthStatusUp
+}
@@ -0,0 +1,246 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/radianteam/framework/adapter"
+	"golang.org/x/exp/slices"
+)
+
+// Structure holds adapters shared by workers and jobs. An adapter
+// is set up on the first Acquire() and closed when the last user
+// releases it. Adapters which are not found are requested from the
+// parent pool (e.g. the pool of the service manager in the monolith
+// mode).
+type AdapterPool struct {
+	mutex sync.Mutex
+
+	entries map[string]*poolEntry
+	names   []string
+
+	parent *AdapterPool
+}
+
+type poolEntry struct {
+	mutex sync.Mutex
+
+	adapter adapter.AdapterInterface
+	refs    int
+}
+
+// Function allocates an empty pool.
+func NewAdapterPool() *AdapterPool {
+	return &AdapterPool{entries: make(map[string]*poolEntry)}
+}
+
+// Function sets the pool used for adapters which are not
+// registered in this one.
+func (p *AdapterPool) SetParent(parent *AdapterPool) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.parent = parent
+}
+
+// Function registers an adapter in the pool. If an adapter with
+// the same name is already registered an error will be thrown.
+func (p *AdapterPool) AddAdapter(adap adapter.AdapterInterface) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.entries[adap.GetName()]; ok {
+		return fmt.Errorf("adapter with name %s has been already registered", adap.GetName())
+	}
+
+	p.entries[adap.GetName()] = &poolEntry{adapter: adap}
+	p.names = append(p.names, adap.GetName())
+
+	return nil
+}
+
+// Function returns names of adapters registered in the pool
+// in the order of registration.
+func (p *AdapterPool) GetNames() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	return slices.Clone(p.names)
+}
+
+// Function returns an adapter from the pool or its parent.
+// The adapter is not set up by this function.
+func (p *AdapterPool) Get(name string) (adapter.AdapterInterface, error) {
+	entry, err := p.entry(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return entry.adapter, nil
+}
+
+func (p *AdapterPool) entry(name string) (*poolEntry, error) {
+	p.mutex.Lock()
+	entry, ok := p.entries[name]
+	parent := p.parent
+	p.mutex.Unlock()
+
+	if ok {
+		return entry, nil
+	}
+
+	if parent != nil {
+		return parent.entry(name)
+	}
+
+	return nil, fmt.Errorf("adapter %s is not found", name)
+}
+
+// Function returns an adapter and increases its reference
+// counter. The adapter and its dependencies are set up by the
+// first call.
+func (p *AdapterPool) Acquire(name string) (adapter.AdapterInterface, error) {
+	if err := p.AcquireAll([]string{name}); err != nil {
+		return nil, err
+	}
+
+	return p.Get(name)
+}
+
+// Function decreases the reference counter of an adapter and its
+// dependencies. The adapter is closed when the counter reaches zero.
+func (p *AdapterPool) Release(name string) error {
+	return p.ReleaseAll([]string{name})
+}
+
+// Function acquires adapters with all their dependencies. Adapters
+// are set up after their dependencies, independent ones in parallel.
+// If any adapter fails the acquired ones are released and all
+// errors are returned.
+func (p *AdapterPool) AcquireAll(names []string) error {
+	levels, err := p.sortAdapters(names)
+
+	if err != nil {
+		return err
+	}
+
+	done, err := adapter.SetupLevels(levels, p.acquireOne)
+
+	if err != nil {
+		return errors.Join(err, adapter.CloseReverse(done, p.releaseOne))
+	}
+
+	return nil
+}
+
+// Function releases adapters acquired by AcquireAll() in the
+// reverse order. All adapters are released even if some of them
+// fail to close.
+func (p *AdapterPool) ReleaseAll(names []string) error {
+	levels, err := p.sortAdapters(names)
+
+	if err != nil {
+		return err
+	}
+
+	return adapter.CloseReverse(flatten(levels), p.releaseOne)
+}
+
+func (p *AdapterPool) acquireOne(name string) error {
+	entry, err := p.entry(name)
+
+	if err != nil {
+		return err
+	}
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	if entry.refs == 0 {
+		if dependent, ok := entry.adapter.(adapter.DependentInterface); ok {
+			for _, dep := range dependent.GetDependencies() {
+				adap, err := p.Get(dep)
+
+				if err != nil {
+					return err
+				}
+
+				dependent.SetDependency(adap)
+			}
+		}
+
+		if err := entry.adapter.Setup(); err != nil {
+			return fmt.Errorf("adapter %s init error %w", name, err)
+		}
+	}
+
+	entry.refs++
+
+	return nil
+}
+
+func (p *AdapterPool) releaseOne(name string) error {
+	entry, err := p.entry(name)
+
+	if err != nil {
+		return err
+	}
+
+	entry.mutex.Lock()
+	defer entry.mutex.Unlock()
+
+	if entry.refs == 0 {
+		return fmt.Errorf("adapter %s is not acquired", name)
+	}
+
+	entry.refs--
+
+	if entry.refs > 0 {
+		return nil
+	}
+
+	if err := entry.adapter.Close(); err != nil {
+		return fmt.Errorf("adapter %s close error %w", name, err)
+	}
+
+	return nil
+}
+
+// Function adds dependencies to the names and groups them by
+// dependency levels.
+func (p *AdapterPool) sortAdapters(names []string) ([][]string, error) {
+	all := []string{}
+	queue := slices.Clone(names)
+
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+
+		if slices.Contains(all, name) {
+			continue
+		}
+
+		adap, err := p.Get(name)
+
+		if err != nil {
+			return nil, err
+		}
+
+		for _, dep := range adapter.GetDependencies(adap) {
+			if _, err := p.Get(dep); err != nil {
+				return nil, fmt.Errorf("adapter %s depends on the adapter %s which is not found", name, dep)
+			}
+		}
+
+		all = append(all, name)
+		queue = append(queue, adapter.GetDependencies(adap)...)
+	}
+
+	return adapter.SortLevels(all, func(name string) []string {
+		adap, _ := p.Get(name)
+
+		return adapter.GetDependencies(adap)
+	})
+}
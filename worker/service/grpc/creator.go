@@ -0,0 +1,38 @@
+package grpc
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/radianteam/framework/adapter/util/config"
+	"github.com/radianteam/framework/worker"
+)
+
+// Function creates the gRPC service worker from a configuration section.
+func GrpcServiceWorkerCreate(name string, configAdapter *config.ConfigAdapter) (worker.WorkerInterface, error) {
+	if configAdapter == nil {
+		return nil, errors.New("configuration adapter is not provided")
+	}
+
+	workerConfig := &GrpcConfig{}
+	err := configAdapter.Unmarshal(workerConfig, false)
+
+	if err != nil {
+		return nil, fmt.Errorf("configuration loading error for the worker with name %s: %v", name, err)
+	}
+
+	return NewGrpcServiceWorker(name, workerConfig), nil
+}
+
+// Function replaces the listen address and the port. The next Run()
+// listens on them, registered services are kept.
+func (w *GrpcServiceWorker) ApplyConfig(configAdapter *config.ConfigAdapter) error {
+	workerConfig := &GrpcConfig{}
+	err := configAdapter.Unmarshal(workerConfig, false)
+
+	if err != nil {
+		return fmt.Errorf("configuration loading error for the worker with name %s: %v", w.GetName(), err)
+	}
+
+	return w.Reconfigure(func() { w.config = workerConfig })
+}
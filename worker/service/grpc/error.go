@@ -8,6 +8,6 @@ import (
 )
 
 func ErrorHandlerGrpc(ctx context.Context, p interface{}) (err error) {
-	logrus.Error("internal server error - %s", p)
+	logrus.Errorf("internal server error - %s", p)
 	return status.Error(codes.Internal, "internal server error")
 }
@@ -1,6 +1,7 @@
 package grpc
 
 import (
+	"context"
 	"fmt"
 	"net"
 
@@ -18,7 +19,7 @@ type RegFuncGrpcServiceWorker func(s *grpc.Server, wc *worker.WorkerAdapters)
 
 type GrpcConfig struct {
 	Listen string `json:"Listen,omitempty" config:"Listen,required"`
-	Port   int16  `json:"Port,omitempty" config:"Port,required"`
+	Port   int16  `json:"Port,omitempty" config:"Port,required,min=1"`
 }
 
 type GrpcServiceWorker struct {
@@ -47,7 +48,7 @@ func (w *GrpcServiceWorker) SetErrorHandler(f grpc_recovery.RecoveryHandlerFuncC
 	w.errorHandler = f
 }
 
-func (w *GrpcServiceWorker) Setup() {
+func (w *GrpcServiceWorker) Setup() error {
 	w.Logger.Infof("Setting up GRPC Service")
 
 	opts := []grpc_logrus.Option{}
@@ -92,21 +93,54 @@ func (w *GrpcServiceWorker) Setup() {
 	if w.IsMonitoringEnable() {
 		grpc_prometheus.Register(w.grpcServer)
 	}
+
+	return nil
 }
 
-func (w *GrpcServiceWorker) Run() {
+// Function serves requests until the context is cancelled and
+// waits for in-flight calls until ForceStop() is called.
+func (w *GrpcServiceWorker) Run(ctx context.Context) error {
 	w.Logger.Infof("Running GRPC Service")
 
+	ctx, cancel := w.RunContext(ctx)
+	defer cancel()
+
 	lis, err := net.Listen("tcp", fmt.Sprintf("%s:%d", w.config.Listen, w.config.Port))
 	if err != nil {
-		w.Logger.Fatalf("failed to listen: %v", err)
+		return fmt.Errorf("failed to listen: %w", err)
 	}
 
-	w.grpcServer.Serve(lis)
-}
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- w.grpcServer.Serve(lis)
+	}()
+
+	w.SetReady(true)
+	defer w.SetReady(false)
+
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+		return fmt.Errorf("serve %w", err)
+	}
+
+	w.SetReady(false)
+
+	stopped := make(chan struct{})
 
-func (w *GrpcServiceWorker) Stop() {
-	w.Logger.Infof("Stop signal received! Graceful shutting down")
+	go func() {
+		w.grpcServer.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-w.WorkContext().Done():
+		w.Logger.Error("Server forced to shutdown")
+
+		w.grpcServer.Stop()
+	}
 
-	w.grpcServer.GracefulStop()
+	return nil
 }
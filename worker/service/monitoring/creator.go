@@ -6,6 +6,7 @@ import (
 
 	"github.com/radianteam/framework"
 	"github.com/radianteam/framework/adapter/util/config"
+	"github.com/radianteam/framework/worker"
 )
 
 func MonitoringMicroserviceCreate(name string, configAdapter *config.ConfigAdapter) (*framework.RadianMicroservice, error) {
@@ -32,3 +33,34 @@ func MonitoringMicroserviceCreate(name string, configAdapter *config.ConfigAdapt
 
 	return microservice, nil
 }
+
+// Function creates the monitoring worker from a configuration section.
+// The worker type is not registered in the framework by default and
+// must be added with RadianServiceManager.AddWorkerType.
+func MonitoringServiceWorkerCreate(name string, configAdapter *config.ConfigAdapter) (worker.WorkerInterface, error) {
+	if configAdapter == nil {
+		return nil, errors.New("configuration adapter is not provided")
+	}
+
+	monitoringConfig := &MonitoringServiceConfig{}
+	err := configAdapter.Unmarshal(monitoringConfig, false)
+
+	if err != nil {
+		return nil, fmt.Errorf("configuration loading error for the worker with name %s: %v", name, err)
+	}
+
+	return NewMonitoringServiceWorker(name, monitoringConfig), nil
+}
+
+// Function replaces the listen address and the port of the metrics
+// and health endpoints. Setup() builds the server from them.
+func (w *MonitoringServiceWorker) ApplyConfig(configAdapter *config.ConfigAdapter) error {
+	workerConfig := &MonitoringServiceConfig{}
+	err := configAdapter.Unmarshal(workerConfig, false)
+
+	if err != nil {
+		return fmt.Errorf("configuration loading error for the worker with name %s: %v", w.GetName(), err)
+	}
+
+	return w.Reconfigure(func() { w.config = workerConfig })
+}
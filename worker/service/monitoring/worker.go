@@ -2,7 +2,9 @@ package monitoring
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
@@ -10,9 +12,11 @@ import (
 	"github.com/radianteam/framework/worker"
 )
 
+const HealthCheckTimeout = 5 * time.Second
+
 type MonitoringServiceConfig struct {
 	Listen string `json:"Listen,omitempty" config:"Listen,required"`
-	Port   int16  `json:"Port,omitempty" config:"Port,required"`
+	Port   int16  `json:"Port,omitempty" config:"Port,required,min=1"`
 }
 
 type MonitoringServiceWorker struct {
@@ -21,6 +25,8 @@ type MonitoringServiceWorker struct {
 	config *MonitoringServiceConfig
 
 	server *http.Server
+
+	healthProvider worker.HealthProviderInterface
 }
 
 func NewMonitoringServiceWorker(name string, config *MonitoringServiceConfig) *MonitoringServiceWorker {
@@ -30,32 +36,96 @@ func NewMonitoringServiceWorker(name string, config *MonitoringServiceConfig) *M
 	}
 }
 
-func (w *MonitoringServiceWorker) Setup() {
+// Function sets the source of health and readiness reports
+// for /healthz and /readyz endpoints. It is called by the
+// microservice or the manager running the worker.
+func (w *MonitoringServiceWorker) SetHealthProvider(provider worker.HealthProviderInterface) {
+	w.healthProvider = provider
+}
+
+func (w *MonitoringServiceWorker) Setup() error {
 	w.Logger.Infof("Setting up monitoring Service")
 
 	srvMux := http.NewServeMux()
 	srvMux.Handle("/metrics", promhttp.Handler())
+	srvMux.HandleFunc("/healthz", w.handleReport(func(ctx context.Context) *worker.HealthReport {
+		return w.healthProvider.CheckHealth(ctx)
+	}))
+	srvMux.HandleFunc("/readyz", w.handleReport(func(ctx context.Context) *worker.HealthReport {
+		return w.healthProvider.CheckReadiness(ctx)
+	}))
 
 	w.server = &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", w.config.Listen, w.config.Port),
 		Handler: srvMux,
 	}
+
+	return nil
 }
 
-func (w *MonitoringServiceWorker) Run() {
-	w.Logger.Infof("Running monitoring Service")
+// Function returns a HTTP handler writing the report as JSON
+// with 200 status if all checks are passed and 503 otherwise.
+func (w *MonitoringServiceWorker) handleReport(check func(ctx context.Context) *worker.HealthReport) http.HandlerFunc {
+	return func(rw http.ResponseWriter, r *http.Request) {
+		report := worker.NewHealthReport()
 
-	if err := w.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		w.Logger.Fatalf("listen %s\n", err)
+		if w.healthProvider != nil {
+			ctx, cancel := context.WithTimeout(r.Context(), HealthCheckTimeout)
+			defer cancel()
+
+			report = check(ctx)
+		}
+
+		status := http.StatusOK
+
+		if !report.IsUp() {
+			status = http.StatusServiceUnavailable
+		}
+
+		rw.Header().Set("Content-Type", "application/json")
+		rw.WriteHeader(status)
+
+		if err := json.NewEncoder(rw).Encode(report); err != nil {
+			w.Logger.Errorf("cannot write health report - %s", err)
+		}
 	}
 }
 
-func (w *MonitoringServiceWorker) Stop() {
-	w.Logger.Infof("stop signal received! Graceful shutting down")
+// Function serves requests until the context is cancelled and
+// waits for in-flight requests until ForceStop() is called.
+func (w *MonitoringServiceWorker) Run(ctx context.Context) error {
+	w.Logger.Infof("Running monitoring Service")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	ctx, cancel := w.RunContext(ctx)
 	defer cancel()
-	if err := w.server.Shutdown(ctx); err != nil {
-		w.Logger.Fatal("Server forced to shutdown: ", err)
+
+	listener, err := net.Listen("tcp", w.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	serveErr := make(chan error, 1)
+
+	go func() {
+		serveErr <- w.server.Serve(listener)
+	}()
+
+	w.SetReady(true)
+	defer w.SetReady(false)
+
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+		return fmt.Errorf("serve %w", err)
 	}
+
+	w.SetReady(false)
+
+	if err := w.server.Shutdown(w.WorkContext()); err != nil {
+		w.Logger.Error("Server forced to shutdown: ", err)
+
+		w.server.Close()
+	}
+
+	return nil
 }
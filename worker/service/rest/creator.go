@@ -0,0 +1,39 @@
+package rest
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/radianteam/framework/adapter/util/config"
+	"github.com/radianteam/framework/worker"
+)
+
+// Function creates the REST service worker from a configuration section.
+func RestServiceWorkerCreate(name string, configAdapter *config.ConfigAdapter) (worker.WorkerInterface, error) {
+	if configAdapter == nil {
+		return nil, errors.New("configuration adapter is not provided")
+	}
+
+	workerConfig := &RestConfig{}
+	err := configAdapter.Unmarshal(workerConfig, false)
+
+	if err != nil {
+		return nil, fmt.Errorf("configuration loading error for the worker with name %s: %v", name, err)
+	}
+
+	return NewRestServiceWorker(name, workerConfig), nil
+}
+
+// Function replaces the listen address, the port and other server
+// settings. Setup() builds the HTTP server from them, routes are
+// kept.
+func (w *RestServiceWorker) ApplyConfig(configAdapter *config.ConfigAdapter) error {
+	workerConfig := &RestConfig{}
+	err := configAdapter.Unmarshal(workerConfig, false)
+
+	if err != nil {
+		return fmt.Errorf("configuration loading error for the worker with name %s: %v", w.GetName(), err)
+	}
+
+	return w.Reconfigure(func() { w.config = workerConfig })
+}
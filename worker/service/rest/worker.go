@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"strings"
 	"time"
@@ -18,7 +19,7 @@ import (
 
 type RestConfig struct {
 	Listen string `json:"Listen,omitempty" config:"Listen,required"`
-	Port   int16  `json:"Port,omitempty" config:"Port,required"`
+	Port   int16  `json:"Port,omitempty" config:"Port,required,min=1"`
 }
 
 type RestServiceWorker struct {
@@ -86,7 +87,13 @@ func (w *RestServiceWorker) SetRoute(method string, path string, handler RestSer
 	handler.SetAdapters(w.Adapters)                                                   // TODO: move to setup
 
 	w.routes.Handle(strings.ToUpper(method), path, func(c *gin.Context) {
+		ctx, cancel := w.HandlerContext(c.Request.Context())
+		defer cancel()
+
+		c.Request = c.Request.WithContext(ctx)
+
 		handler.SetGinContext(c)
+		handler.SetContext(ctx)
 
 		err := handler.Handle()
 
@@ -98,31 +105,64 @@ func (w *RestServiceWorker) SetRoute(method string, path string, handler RestSer
 	})
 }
 
-func (w *RestServiceWorker) Setup() {
+func (w *RestServiceWorker) Setup() error {
 	w.Logger.Info("Setting up REST Service")
 
-	prometheus.MustRegister(w.metricRequestCount) // TODO: refactor to Register
+	if err := prometheus.Register(w.metricRequestCount); err != nil {
+		if _, ok := err.(prometheus.AlreadyRegisteredError); !ok {
+			return err
+		}
+	}
 
 	w.server = &http.Server{
 		Addr:    fmt.Sprintf("%s:%d", w.config.Listen, w.config.Port),
 		Handler: w.routes,
 	}
+
+	return nil
 }
 
-func (w *RestServiceWorker) Run() {
+// Function serves requests until the context is cancelled. Then
+// it stops accepting connections and waits for in-flight requests
+// until ForceStop() is called. Request contexts are derived from the
+// work context so handlers are cancelled only by the force stop.
+func (w *RestServiceWorker) Run(ctx context.Context) error {
 	w.Logger.Info("Running REST Service")
 
-	if err := w.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-		w.Logger.Fatalf("listen %s\n", err)
+	ctx, cancel := w.RunContext(ctx)
+	defer cancel()
+
+	listener, err := net.Listen("tcp", w.server.Addr)
+	if err != nil {
+		return err
+	}
+
+	w.server.BaseContext = func(net.Listener) context.Context {
+		return w.WorkContext()
 	}
-}
 
-func (w *RestServiceWorker) Stop() {
-	w.Logger.Info("stop signal received! Graceful shutting down")
+	serveErr := make(chan error, 1)
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
-	if err := w.server.Shutdown(ctx); err != nil {
-		w.Logger.Fatal("Server forced to shutdown: ", err)
+	go func() {
+		serveErr <- w.server.Serve(listener)
+	}()
+
+	w.SetReady(true)
+	defer w.SetReady(false)
+
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+		return fmt.Errorf("serve %w", err)
 	}
+
+	w.SetReady(false)
+
+	if err := w.server.Shutdown(w.WorkContext()); err != nil {
+		w.Logger.Error("Server forced to shutdown: ", err)
+
+		w.server.Close()
+	}
+
+	return nil
 }
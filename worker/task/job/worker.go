@@ -18,6 +18,12 @@ func NewTaskJob(name string, handler TaskJobHandlerInterface) *TaskJob {
 	return &TaskJob{BaseWorker: worker.NewBaseWorker(name), Handler: handler}
 }
 
+// Internal function to execute during the framework starting. A job
+// can be added to a microservice as a permanent worker as well.
+func (w *TaskJob) Setup() error {
+	return nil
+}
+
 // Internal function. Main loop used in framework loop as a separated thread
 func (w *TaskJob) Run(ctx context.Context) (err error) {
 	w.Logger.Infof("Running Job: %s", w.GetName())
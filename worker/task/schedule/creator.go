@@ -0,0 +1,13 @@
+package schedule
+
+import (
+	"github.com/radianteam/framework/adapter/util/config"
+	"github.com/radianteam/framework/worker"
+)
+
+// Function creates the scheduler worker for the framework registry.
+// The scheduler has no configuration so tasks must be added by the
+// application after the microservice has been built.
+func TaskScheduleCreate(name string, _ *config.ConfigAdapter) (worker.WorkerInterface, error) {
+	return NewTaskSchedule(name), nil
+}
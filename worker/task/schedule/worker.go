@@ -2,6 +2,7 @@ package schedule
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/procyon-projects/chrono"
@@ -35,8 +36,6 @@ type TaskSchedule struct {
 
 	scheduler chrono.TaskScheduler
 
-	waitChan chan bool
-
 	tasks []Task
 }
 
@@ -64,22 +63,32 @@ func (w *TaskSchedule) AddCronTask(cronStr string, handler TaskScheduleHandlerIn
 }
 
 // Internal function to execute during the framework starting
-func (w *TaskSchedule) Setup() {
+func (w *TaskSchedule) Setup() error {
 	w.Logger.Info("Setting up Task Scheduler")
 
 	w.scheduler = chrono.NewDefaultTaskScheduler()
+
+	return nil
 }
 
-// Internal function. Main loop used in framework loop as a separated thread
-func (w *TaskSchedule) Run() {
+// Internal function. Main loop used in framework loop as a separated
+// thread. After the context is cancelled no new tasks are started and
+// running ones are awaited. Task contexts are cancelled by ForceStop().
+func (w *TaskSchedule) Run(ctx context.Context) (err error) {
 	w.Logger.Info("Running Task scheduler")
 
+	ctx, cancel := w.RunContext(ctx)
+	defer cancel()
+
 	for _, task := range w.tasks {
 		taskScope := task
 
-		handler := func(ctx context.Context) {
+		handler := func(context.Context) {
+			handlerCtx, cancelHandler := w.HandlerContext(w.WorkContext())
+			defer cancelHandler()
+
 			taskScope.Handler.SetAdapters(w.Adapters)
-			taskScope.Handler.SetContext(ctx)
+			taskScope.Handler.SetContext(handlerCtx)
 
 			err := taskScope.Handler.Handle()
 
@@ -89,28 +98,33 @@ func (w *TaskSchedule) Run() {
 		}
 
 		if task.Type == TaskTypeFixedDelay {
-			w.scheduler.ScheduleWithFixedDelay(handler, taskScope.Delay)
+			_, err = w.scheduler.ScheduleWithFixedDelay(handler, taskScope.Delay)
 		} else if task.Type == TaskTypeDelay {
-			w.scheduler.ScheduleAtFixedRate(handler, taskScope.Delay)
+			_, err = w.scheduler.ScheduleAtFixedRate(handler, taskScope.Delay)
 		} else if task.Type == TaskTypeCron {
-			w.scheduler.ScheduleWithCron(handler, taskScope.CronStr)
+			_, err = w.scheduler.ScheduleWithCron(handler, taskScope.CronStr)
 		}
-	}
 
-	w.waitChan = make(chan bool)
+		if err != nil {
+			<-w.scheduler.Shutdown()
+
+			return fmt.Errorf("task scheduling error: %w", err)
+		}
+	}
 
-	<-w.waitChan
+	w.SetReady(true)
 
-	w.Logger.Info("Stopping Task Scheduler")
+	<-ctx.Done()
 
-	wait := w.scheduler.Shutdown()
+	w.SetReady(false)
 
-	<-wait
-}
+	w.Logger.Info("Stopping Task Scheduler")
 
-// Internal function to execute during the framework stopping
-func (w *TaskSchedule) Stop() {
-	w.Logger.Info("stop signal received! Graceful shutting down")
+	select {
+	case <-w.scheduler.Shutdown():
+	case <-w.WorkContext().Done():
+		w.Logger.Error("Task Scheduler forced to shutdown")
+	}
 
-	close(w.waitChan)
+	return
 }
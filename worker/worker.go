@@ -1,36 +1,62 @@
 package worker
 
 import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/radianteam/framework/adapter"
 	"github.com/sirupsen/logrus"
 )
 
+// Error of a configuration change applied while Run() is in
+// progress.
+var ErrWorkerRunning = errors.New("worker is running, stop it before applying the configuration")
+
 // Interface implements basic worker functions. All new workers
-// must inherit BaseWorker structure and implement only Setup(),
-// Run() and Stop() functions.
+// must inherit BaseWorker structure and implement only Setup()
+// and Run() functions. Run() blocks until the context is cancelled
+// or Stop() is called and returns an error if the worker cannot
+// work anymore.
 type WorkerInterface interface {
 	GetName() string
 	SetName(string)
 	SetMicroserviceName(string)
 	SetAdapter(adapter.AdapterInterface)
+	UseAdapter(names ...string)
+	GetUsedAdapterNames() []string
+	AttachAdapter(adapter.AdapterInterface)
 	SetupAdapters() error
 	CloseAdapters() error
-	Setup()
-	Run()
+	Setup() error
+	Run(ctx context.Context) error
 	Stop()
+	ForceStop()
 	SetMonitoring(enabled bool)
 	IsMonitoringEnable() bool
+	IsReady() bool
+	CheckHealth(ctx context.Context) *HealthReport
 }
 
 // Worker structure contains an adapter list and implements
 // functions to control adapters. All new workers must inherit
-// BaseWorker and implement only Setup(), Run() and Stop()
-// functions from WorkerInterface.
+// BaseWorker and implement only Setup() and Run() functions
+// from WorkerInterface.
 type BaseWorker struct {
 	name              string
 	monitoringEnabled bool
+	ready             atomic.Bool
+	handlerTimeout    time.Duration
 	Logger            *logrus.Entry
 	Adapters          *WorkerAdapters
+
+	mutex       sync.Mutex
+	running     bool
+	cancel      context.CancelFunc
+	work        context.Context
+	forceCancel context.CancelFunc
 }
 
 // Function allocates BaseWorker structure with JSON logger
@@ -79,6 +105,24 @@ func (w *BaseWorker) SetAdapter(adap adapter.AdapterInterface) {
 	w.Adapters.SetAdapter(adap)
 }
 
+// Function requests shared adapters of the microservice by
+// names. A shared adapter is set up once for all workers and
+// jobs of the microservice which use it.
+func (w *BaseWorker) UseAdapter(names ...string) {
+	w.Adapters.UseAdapter(names...)
+}
+
+// Function returns names of requested shared adapters.
+func (w *BaseWorker) GetUsedAdapterNames() []string {
+	return w.Adapters.GetUsedAdapterNames()
+}
+
+// Function appends a shared adapter from the microservice pool.
+// It is called by the microservice before the worker is started.
+func (w *BaseWorker) AttachAdapter(adap adapter.AdapterInterface) {
+	w.Adapters.AttachAdapter(adap)
+}
+
 // Function setups all adapters and is used in the main
 // framework loop.
 func (w *BaseWorker) SetupAdapters() error {
@@ -101,3 +145,117 @@ func (w *BaseWorker) SetMonitoring(enabled bool) {
 func (w *BaseWorker) IsMonitoringEnable() bool {
 	return w.monitoringEnabled
 }
+
+// Function marks the worker as ready (or not) to accept
+// requests. Workers set it when they start listening.
+func (w *BaseWorker) SetReady(ready bool) {
+	w.ready.Store(ready)
+}
+
+// Function returns the readiness status of the worker.
+func (w *BaseWorker) IsReady() bool {
+	return w.ready.Load()
+}
+
+// Function checks the worker's adapters.
+func (w *BaseWorker) CheckHealth(ctx context.Context) *HealthReport {
+	return w.Adapters.HealthCheck(ctx)
+}
+
+// Function derives the context of the current run from the
+// context passed to Run(). The context is cancelled by Stop()
+// and means the worker must stop accepting new requests. It also
+// creates the work context for in-flight requests which is
+// cancelled only by ForceStop() or the returned cancel function.
+func (w *BaseWorker) RunContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	ctx, cancelRun := context.WithCancel(ctx)
+	work, cancelWork := context.WithCancel(context.Background())
+
+	w.cancel, w.work, w.forceCancel = cancelRun, work, cancelWork
+	w.running = true
+
+	return ctx, func() {
+		cancelRun()
+		cancelWork()
+
+		w.mutex.Lock()
+		w.running = false
+		w.mutex.Unlock()
+	}
+}
+
+// Function calls apply if Run() is not in progress and returns
+// ErrWorkerRunning otherwise. Run() does not start until apply
+// returns, so fields read by Run() can be replaced safely.
+func (w *BaseWorker) Reconfigure(apply func()) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.running {
+		return ErrWorkerRunning
+	}
+
+	apply()
+
+	return nil
+}
+
+// Function returns the work context of the current run. Handler
+// contexts are derived from it, so in-flight requests are not
+// cancelled when the worker stops accepting new ones.
+func (w *BaseWorker) WorkContext() context.Context {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.work == nil {
+		return context.Background()
+	}
+
+	return w.work
+}
+
+// Function cancels the work context. In-flight requests are
+// interrupted and the worker must return from Run() immediately.
+func (w *BaseWorker) ForceStop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.forceCancel != nil {
+		w.Logger.Warn("force stop signal received! Interrupting in-flight requests")
+
+		w.forceCancel()
+	}
+}
+
+// Function cancels the context of the current run. Workers
+// stop after the context has been cancelled.
+func (w *BaseWorker) Stop() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.cancel != nil {
+		w.Logger.Info("stop signal received! Graceful shutting down")
+
+		w.cancel()
+	}
+}
+
+// Function sets the maximum duration of a handler call.
+// Zero means no deadline.
+func (w *BaseWorker) SetHandlerTimeout(timeout time.Duration) {
+	w.handlerTimeout = timeout
+}
+
+// Function derives a request or message scoped context for a
+// handler. The context has a deadline if the handler timeout
+// is set.
+func (w *BaseWorker) HandlerContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if w.handlerTimeout > 0 {
+		return context.WithTimeout(ctx, w.handlerTimeout)
+	}
+
+	return context.WithCancel(ctx)
+}